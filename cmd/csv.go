@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/DalvinCodes/cars/csvio"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/service"
+)
+
+// runCommand runs the named subcommand against the storage selected on the
+// command line.
+func runCommand(name string, args []string) error {
+	var run func(carService service.CarService, args []string) error
+	switch name {
+	case "export":
+		run = exportCommand
+	case "import":
+		run = importCommand
+	default:
+		return fmt.Errorf("unknown command %q, want export or import", name)
+	}
+
+	repo, closeRepo, err := newStorage()
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	return run(service.NewCarService(repository.NewHistoryStore(repo, time.Now)), args)
+}
+
+// exportCommand writes the live cars as CSV to a file or standard output.
+//
+//	cars [flags] export [-columns id,make,...] [-header=false] [-o cars.csv]
+func exportCommand(carService service.CarService, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	columns := flags.String("columns", "", "comma separated columns to export, all the default columns when empty")
+	header := flags.Bool("header", true, "write a header row")
+	output := flags.String("o", "", "file to write the CSV to, standard output when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+
+	writer, err := csvio.NewWriter(buffered, csvio.ParseColumns(*columns), *header)
+	if err != nil {
+		return err
+	}
+
+	if err := carService.StreamCars(model.Query{}, writer.Write); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// importCommand saves the cars of a CSV file, or standard input, and prints the
+// rows it could not save.
+//
+//	cars [flags] import [-columns id,make,...] [-header=false] [cars.csv]
+func importCommand(carService service.CarService, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	columns := flags.String("columns", "", "comma separated columns of the file, read from the header when empty")
+	header := flags.Bool("header", true, "the first row is a header")
+	actor := flags.String("actor", "", "who the import is recorded as made by")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	reader, err := csvio.NewReader(bufio.NewReader(in), csvio.ParseColumns(*columns), *header)
+	if err != nil {
+		return err
+	}
+	report, err := csvio.Import(reader, func(car *model.Car) (bool, error) {
+		car.UpdatedBy = *actor
+		return carService.ImportCar(car)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range report.Errors {
+		log.Printf("row %d: %v\n", rowErr.Line, rowErr.Err)
+	}
+	log.Printf("Imported %d cars, created %d, updated %d, failed %d...", report.Created+report.Updated, report.Created, report.Updated, report.Failed)
+	return nil
+}
@@ -1,35 +1,200 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/DalvinCodes/cars/controller"
 	"github.com/DalvinCodes/cars/middleware"
 	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/router"
 	"github.com/DalvinCodes/cars/service"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	storageKind     = flag.String("storage", "memory", "storage backend to use: memory, sharded, file or sqlite")
+	shards          = flag.Int("shards", repository.DefaultShards, "number of partitions used by the sharded storage backend")
+	dataDir         = flag.String("data-dir", "data", "directory used by the file storage backend")
+	compactInterval = flag.Duration("compact-interval", 5*time.Minute, "how often the file storage backend compacts its log")
+	syncWrites      = flag.Bool("sync-writes", false, "fsync the file storage backend log after every write")
+	dsn             = flag.String("dsn", "cars.db", "data source name used by the sqlite storage backend")
+	cacheSize       = flag.Int("cache-size", 0, "number of reads cached in front of the storage backend, 0 to disable the cache")
+	cacheTTL        = flag.Duration("cache-ttl", time.Minute, "how long a cached read is served, 0 to keep it until it is evicted")
+	cacheStats      = flag.Duration("cache-stats-interval", 5*time.Minute, "how often the cache logs how it answered reads, 0 to never log them")
+	purgeRetention  = flag.Duration("purge-retention", 30*24*time.Hour, "how long soft deleted cars are kept before they are purged")
+	purgeInterval   = flag.Duration("purge-interval", time.Hour, "how often soft deleted cars are checked for purging, 0 to never purge")
 )
 
 func main() {
+	flag.Parse()
+
+	// run a one-off command instead of the server when one is given
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Arg(0), flag.Args()[1:]); err != nil {
+			log.Fatalf("error while running the %s command. err: %v\n", flag.Arg(0), err)
+		}
+		return
+	}
+
 	log.Println("Starting the application...")
 
 	// create the dependencies
-	repo := repository.NewRepo()
-	carService := service.NewCarService(repo)
+	repo, closeRepo, err := newStorage()
+	if err != nil {
+		log.Fatalf("error while creating the storage. err: %v\n", err)
+	}
+	defer closeRepo()
+
+	// keep recent reads in memory in front of the backend
+	if *cacheSize > 0 {
+		cache := repository.NewCacheStore(repo, repository.CacheOptions{
+			TTL:        *cacheTTL,
+			MaxEntries: *cacheSize,
+		})
+		if *cacheStats > 0 {
+			go cacheStatsLoop(cache, *cacheStats)
+		}
+		repo = cache
+	}
+
+	// remember every revision so past states can be read back
+	history := repository.NewHistoryStore(repo, time.Now)
+
+	// index the cars for full-text search
+	indexed, err := repository.NewSearchStore(history)
+	if err != nil {
+		log.Fatalf("error while indexing the cars. err: %v\n", err)
+	}
+
+	carService := service.NewCarService(indexed)
+	if *purgeInterval > 0 {
+		go purgeLoop(carService, *purgeInterval, *purgeRetention)
+	}
 	carController := controller.NewCarController(carService)
 
 	// register the handlers
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1/cars/create", middleware.AddHeaders(middleware.LoggingMiddleware(carController.CreateCarHandler)))
-	mux.HandleFunc("/api/v1/cars/", middleware.AddHeaders(middleware.LoggingMiddleware(carController.GetCarHandler)))
-	mux.HandleFunc("/api/v1/cars/all", middleware.AddHeaders(middleware.LoggingMiddleware(carController.GetCarsHandler)))
-	mux.HandleFunc("/api/v1/cars/update", middleware.AddHeaders(middleware.LoggingMiddleware(carController.UpdateCarHandler)))
-	mux.HandleFunc("/api/v1/cars/delete", middleware.AddHeaders(middleware.LoggingMiddleware(carController.DeleteCarHandler)))
+	mux := newRouter(carController)
+	handler := middleware.AddHeaders(middleware.LoggingMiddleware(mux.ServeHTTP))
 
 	// start the server
 	log.Println("Starting server and listening on port 8080...")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
+	if err := http.ListenAndServe(":8080", handler); err != nil {
 		log.Println("server stopped...")
 		panic(err)
 	}
 }
+
+// newRouter maps the car resources onto the controller.
+func newRouter(carController *controller.CarsController) *router.Router {
+	mux := router.New()
+	mux.NotFound = controller.NotFoundHandler
+	mux.MethodNotAllowed = controller.MethodNotAllowedHandler
+
+	mux.Handle(http.MethodGet, "/api/v1/cars", carController.GetCarsHandler)
+	mux.Handle(http.MethodPost, "/api/v1/cars", carController.CreateCarHandler)
+	mux.Handle(http.MethodPost, "/api/v1/cars/batch", carController.BatchCarsHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/export", carController.ExportCarsHandler)
+	mux.Handle(http.MethodPost, "/api/v1/cars/import", carController.ImportCarsHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/search", carController.SearchCarsHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/facets", carController.GetCarFacetsHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/{id}", carController.GetCarHandler)
+	mux.Handle(http.MethodPut, "/api/v1/cars/{id}", carController.UpdateCarHandler)
+	mux.Handle(http.MethodPatch, "/api/v1/cars/{id}", carController.PatchCarHandler)
+	mux.Handle(http.MethodDelete, "/api/v1/cars/{id}", carController.DeleteCarHandler)
+	mux.Handle(http.MethodPost, "/api/v1/cars/{id}/restore", carController.RestoreCarHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/{id}/revisions", carController.GetCarRevisionsHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/{id}/revisions/{revision}", carController.GetCarRevisionHandler)
+	mux.Handle(http.MethodGet, "/api/v1/cars/{id}/diff", carController.DiffCarRevisionsHandler)
+
+	registerLegacyRoutes(mux, carController)
+	return mux
+}
+
+// registerLegacyRoutes keeps the original verb-in-path routes, which take the
+// car id from ?id=, working for existing clients.
+func registerLegacyRoutes(mux *router.Router, carController *controller.CarsController) {
+	mux.Handle(http.MethodPost, "/api/v1/cars/create", deprecated(carController.CreateCarHandler))
+	mux.Handle(http.MethodGet, "/api/v1/cars/", deprecated(carController.GetCarHandler))
+	mux.Handle(http.MethodGet, "/api/v1/cars/all", deprecated(carController.GetCarsHandler))
+	mux.Handle(http.MethodPut, "/api/v1/cars/update", deprecated(carController.UpdateCarHandler))
+	mux.Handle(http.MethodDelete, "/api/v1/cars/delete", deprecated(carController.DeleteCarHandler))
+}
+
+// deprecated flags responses from legacy routes so clients can find and migrate them.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next(w, r)
+	}
+}
+
+// newStorage builds the storage backend selected on the command line and a
+// function that releases it.
+func newStorage() (repository.Storage, func() error, error) {
+	switch *storageKind {
+	case "memory":
+		return repository.NewRepo(), func() error { return nil }, nil
+	case "sharded":
+		log.Printf("Using sharded memory storage with %d shards...", *shards)
+		return repository.NewShardedRepo(*shards), func() error { return nil }, nil
+	case "file":
+		log.Printf("Using file storage in %s...", *dataDir)
+		store, err := repository.NewFileStore(*dataDir, repository.FileStoreOptions{
+			CompactInterval: *compactInterval,
+			SyncWrites:      *syncWrites,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store.Close, nil
+	case "sqlite":
+		log.Printf("Using sqlite storage at %s...", *dsn)
+		db, err := sql.Open("sqlite", repository.SQLiteDSN(*dsn))
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := repository.NewSQLStore(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return store, db.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", *storageKind)
+	}
+}
+
+// purgeLoop removes cars that have been soft deleted for longer than retention,
+// checking every interval.
+func purgeLoop(carService service.CarService, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := carService.PurgeDeletedCars(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("error while purging deleted cars. err: %v\n", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Purged %d deleted cars...", purged)
+		}
+	}
+}
+
+// cacheStatsLoop logs how the cache answered reads so far, every interval.
+func cacheStatsLoop(cache *repository.CacheStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		stats := cache.Stats()
+		log.Printf("Cache holds %d results after %d hits, %d misses (%d shared) and %d evictions...",
+			stats.Entries, stats.Hits, stats.Misses, stats.Shared, stats.Evictions)
+	}
+}
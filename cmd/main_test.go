@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/controller"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes(t *testing.T) {
+	store, err := repository.NewSearchStore(repository.NewHistoryStore(repository.NewRepo(), time.Now))
+	assert.NoError(t, err)
+	carService := service.NewCarService(store)
+	server := httptest.NewServer(newRouter(controller.NewCarController(carService)))
+	defer server.Close()
+
+	do := func(method, path string, body []byte) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		assert.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	car := []byte(`{"make":"Honda","model":"Civic","year":2019,"price":2000000}`)
+
+	t.Run("Resource routes", func(t *testing.T) {
+		resp := do("POST", "/api/v1/cars", car)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		location := resp.Header.Get("Location")
+		assert.Contains(t, location, "/api/v1/cars/")
+
+		resp = do("GET", location, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("PUT", location, car)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		resp = do("POST", location, car)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "DELETE, GET, HEAD, OPTIONS, PATCH, PUT", resp.Header.Get("Allow"))
+
+		resp = do("DELETE", location, nil)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = do("GET", location, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = do("GET", location+"?include_deleted=true", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("POST", location+"/restore", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("GET", location, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Legacy routes", func(t *testing.T) {
+		resp := do("POST", "/api/v1/cars/create", car)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+
+		var created model.Car
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+		resp = do("GET", "/api/v1/cars/?id="+created.ID, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("GET", "/api/v1/cars/all", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("PUT", "/api/v1/cars/update?id="+created.ID, car)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		resp = do("DELETE", "/api/v1/cars/delete?id="+created.ID, nil)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = do("GET", "/api/v1/cars/delete?id="+created.ID, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("History routes", func(t *testing.T) {
+		resp := do("POST", "/api/v1/cars", car)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		location := resp.Header.Get("Location")
+
+		resp = do("PUT", location, []byte(`{"make":"Honda","model":"Civic","year":2019,"price":1900000}`))
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		resp = do("GET", location+"/revisions", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var revisions []model.Revision
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&revisions))
+		assert.Len(t, revisions, 2)
+
+		resp = do("GET", location+"/revisions/2", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("GET", location+"/diff?from=1&to=2", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var changes []model.Change
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&changes))
+		assert.Equal(t, "price", changes[0].Field)
+
+		resp = do("GET", location+"?as_of="+revisions[0].At.Format(time.RFC3339Nano), nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var asOf model.Car
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&asOf))
+		assert.Equal(t, 2000000, asOf.Price)
+	})
+
+	t.Run("Search route", func(t *testing.T) {
+		resp := do("POST", "/api/v1/cars", []byte(`{"make":"Mazda","model":"Miata","year":2020}`))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		resp = do("GET", "/api/v1/cars/search?q=miata", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var hits []model.SearchHit
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&hits))
+		if assert.Len(t, hits, 1) {
+			assert.Equal(t, "Mazda", hits[0].Car.Make)
+		}
+	})
+
+	t.Run("Facets route", func(t *testing.T) {
+		resp := do("GET", "/api/v1/cars/facets?facets=make", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var facets model.Facets
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&facets))
+		assert.Equal(t, "make", facets.Facets[0].Field)
+	})
+
+	t.Run("CSV routes", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/api/v1/cars/import", "text/csv", strings.NewReader("make,model,year\nKia,Soul,2021\n"))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = do("GET", "/api/v1/cars/export?make=Kia&columns=make,model", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "make,model\nKia,Soul\n", string(body))
+	})
+}
+
+func TestCSVCommands(t *testing.T) {
+	carService := service.NewCarService(repository.NewRepo())
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "in.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("make,model,year\nHonda,Civic,2019\nKia,Soul,2021\n"), 0o644))
+	assert.NoError(t, importCommand(carService, []string{input}))
+
+	output := filepath.Join(dir, "out.csv")
+	assert.NoError(t, exportCommand(carService, []string{"-columns", "model", "-header=false", "-o", output}))
+
+	exported, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Civic", "Soul"}, strings.Fields(string(exported)))
+
+	assert.Error(t, runCommand("serve", nil))
+}
@@ -0,0 +1,77 @@
+// Package codec encodes and decodes request and response bodies in the media
+// types the API speaks: JSON, XML, YAML and MessagePack. A Registry picks the
+// codec for a request from its Accept and Content-Type headers.
+package codec
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// Codec reads and writes values in one media type.
+type Codec interface {
+	// MediaTypes lists the media types the codec speaks, the canonical one
+	// first.
+	MediaTypes() []string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// Registry holds the codecs a server offers. The first one is the default,
+// used for bodies that name no media type and clients that accept anything.
+type Registry struct {
+	codecs  []Codec
+	byType  map[string]Codec
+	offered []string
+}
+
+// NewRegistry offers the given codecs, the first as the default.
+func NewRegistry(codecs ...Codec) *Registry {
+	registry := &Registry{
+		codecs: codecs,
+		byType: make(map[string]Codec),
+	}
+	for _, codec := range codecs {
+		for _, mediaType := range codec.MediaTypes() {
+			registry.byType[mediaType] = codec
+			registry.offered = append(registry.offered, mediaType)
+		}
+	}
+	return registry
+}
+
+// Default offers JSON, then XML, YAML and MessagePack.
+func Default() *Registry {
+	return NewRegistry(JSON, XML, YAML, MessagePack)
+}
+
+// MediaTypes lists every media type the registry speaks, the default first.
+func (r *Registry) MediaTypes() []string {
+	return append([]string(nil), r.offered...)
+}
+
+// Lookup returns the codec for mediaType, if there is one.
+func (r *Registry) Lookup(mediaType string) (Codec, bool) {
+	codec, ok := r.byType[mediaType]
+	return codec, ok
+}
+
+// ForContentType returns the codec for the media type a Content-Type header
+// names, the default one when it is empty, or utils.ErrUnsupportedMediaType.
+func (r *Registry) ForContentType(contentType string) (Codec, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return r.codecs[0], nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if codec, ok := r.byType[mediaType]; ok {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: request bodies can be %s", utils.ErrUnsupportedMediaType, strings.Join(r.offered, ", "))
+}
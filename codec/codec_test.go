@@ -0,0 +1,108 @@
+package codec_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/codec"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	car := &model.Car{ID: "2019", Make: "Honda", Model: "Civic", Year: 2019, Price: 2000000, Version: 3, UpdatedBy: "sam", DeletedAt: &deletedAt}
+
+	for _, c := range []codec.Codec{codec.JSON, codec.XML, codec.YAML, codec.MessagePack} {
+		t.Run(c.MediaTypes()[0], func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, c.Encode(&buf, car))
+
+			var decoded *model.Car
+			assert.NoError(t, c.Decode(&buf, &decoded))
+			assert.Equal(t, car, decoded)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cars := []*model.Car{{ID: "a", Make: "Honda", Model: "Civic"}, {ID: "b", Make: "Kia", Model: "Soul"}}
+
+	t.Run("XML wraps slices in the plural of their elements", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, codec.XML.Encode(&buf, cars))
+
+		out := buf.String()
+		assert.True(t, strings.HasPrefix(out, "<?xml"))
+		assert.Contains(t, out, "<cars>\n  <car>\n    <id>a</id>")
+		assert.Equal(t, 2, strings.Count(out, "<car>"))
+		assert.NotContains(t, out, "deleted_at")
+	})
+
+	t.Run("YAML keeps the JSON names and order", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, codec.YAML.Encode(&buf, cars[0]))
+
+		assert.Equal(t, "id: a\nmake: Honda\nmodel: Civic\npackage: \"\"\ncolor: \"\"\ncategory: \"\"\nyear: 0\nmileage: 0\nprice: 0\nversion: 0\n", buf.String())
+	})
+
+	t.Run("MessagePack is smaller than JSON", func(t *testing.T) {
+		var packed, plain bytes.Buffer
+		assert.NoError(t, codec.MessagePack.Encode(&packed, cars))
+		assert.NoError(t, codec.JSON.Encode(&plain, cars))
+
+		assert.Less(t, packed.Len(), plain.Len())
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	registry := codec.Default()
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        codec.Codec
+		err         error
+	}{
+		{"Default to JSON", "", codec.JSON, nil},
+		{"Ignore parameters", "application/json; charset=utf-8", codec.JSON, nil},
+		{"Alias", "text/yaml", codec.YAML, nil},
+		{"MessagePack", "application/msgpack", codec.MessagePack, nil},
+		{"Unsupported", "text/plain", nil, utils.ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.ForContentType(tt.contentType)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "application/yaml"}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"No header", "", "application/json"},
+		{"Exact type", "application/xml", "application/xml"},
+		{"Anything", "*/*", "application/json"},
+		{"Quality", "application/json;q=0.5, application/yaml", "application/yaml"},
+		{"Specific beats wildcard", "*/*;q=0.9, application/xml", "application/xml"},
+		{"Refused type", "application/*, application/json;q=0", "application/xml"},
+		{"Nothing acceptable", "text/html", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, codec.Negotiate(tt.accept, offers...))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack speaks application/msgpack. Values are written through their
+// JSON encoding, so maps carry the JSON field names.
+var MessagePack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+
+	encoder := msgpack.NewEncoder(w)
+	encoder.UseCompactInts(true)
+	encoder.SetSortMapKeys(true)
+	return encoder.Encode(generic)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	var generic any
+	if err := msgpack.NewDecoder(r).Decode(&generic); err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the offered media type the Accept header rates highest,
+// preferring on ties the one it names most specifically, then earlier offers.
+// A missing header accepts the first offer; "" means the header accepts none.
+func Negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		q, specificity := acceptQuality(accept, offer)
+		if q > bestQ || (q == bestQ && q > 0 && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the most specific range of the Accept
+// header that covers offer gives it, and how specific that range is: 2 for the
+// exact type, 1 for type/* and 0 for */*.
+func acceptQuality(accept, offer string) (float64, int) {
+	offerType, _, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		rangeSpecificity := -1
+		switch {
+		case mediaType == offer:
+			rangeSpecificity = 2
+		case mediaType == offerType+"/*":
+			rangeSpecificity = 1
+		case mediaType == "*/*":
+			rangeSpecificity = 0
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+
+		rangeQ := 1.0
+		if raw, ok := params["q"]; ok {
+			if rangeQ, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		q, specificity = rangeQ, rangeSpecificity
+	}
+	return q, specificity
+}
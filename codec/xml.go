@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// XML speaks application/xml. Values are written under an element named after
+// their type in snake case, so a car is a <car>, and slices are wrapped in the
+// plural of their elements, so []*model.Car is <cars><car>...</car></cars>.
+// Fields are named by their xml struct tags.
+var XML Codec = xmlCodec{}
+
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice {
+		if err := encoder.EncodeElement(v, element(elementName(value.Type()))); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+
+	name := elementName(value.Type().Elem())
+	list := element(name + "s")
+	if err := encoder.EncodeToken(list); err != nil {
+		return err
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.EncodeElement(value.Index(i).Interface(), element(name)); err != nil {
+			return err
+		}
+	}
+	if err := encoder.EncodeToken(list.End()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func element(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+// elementName turns the name of t, or of what it points to, into snake case.
+func elementName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return "value"
+	}
+
+	var name strings.Builder
+	for i, r := range t.Name() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
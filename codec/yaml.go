@@ -0,0 +1,55 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML speaks application/yaml. Values are written through their JSON
+// encoding, so fields keep their JSON names and order.
+var YAML Codec = yamlCodec{}
+
+type yamlCodec struct{}
+
+func (yamlCodec) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+func (yamlCodec) Encode(w io.Writer, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// JSON is YAML, so parse it and drop the flow style and quoting it came
+	// with; the encoder quotes whatever would otherwise read back differently
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func (yamlCodec) Decode(r io.Reader, v any) error {
+	var generic any
+	if err := yaml.NewDecoder(r).Decode(&generic); err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
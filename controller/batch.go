@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// batchRequest is the body of a batch: operations run in order and, when
+// atomic is set, all succeed or none do.
+type batchRequest struct {
+	Atomic     bool                   `json:"atomic" xml:"atomic"`
+	Operations []model.BatchOperation `json:"operations" xml:"operations>operation"`
+}
+
+// batchItem reports the outcome of the operation at the same index, with the
+// status code the operation would have had on its own.
+type batchItem struct {
+	Status int        `json:"status" xml:"status"`
+	ID     string     `json:"id,omitempty" xml:"id,omitempty"`
+	Car    *model.Car `json:"car,omitempty" xml:"car,omitempty"`
+	Error  *Problem   `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+var batchStatuses = map[string]int{
+	model.BatchCreate: http.StatusCreated,
+	model.BatchUpdate: http.StatusOK,
+	model.BatchDelete: http.StatusNoContent,
+}
+
+func (c *CarsController) BatchCarsHandler(w http.ResponseWriter, r *http.Request) {
+	var batch batchRequest
+
+	// pick how to encode the response before changing anything
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// decode the request body into the batch
+	if err := c.decodeBody(r, &batch); err != nil {
+		log.Printf("error while decoding the batch. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// record who made the changes
+	actor := r.Header.Get(actorHeaderName)
+	for i := range batch.Operations {
+		batch.Operations[i].Actor = actor
+	}
+
+	// run the batch
+	results, err := c.service.ApplyBatch(batch.Operations, batch.Atomic)
+	if err != nil {
+		log.Printf("error while applying the batch. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// report every operation, and whether any failed in the status code
+	status := http.StatusOK
+	items := make([]batchItem, len(results))
+	for i, result := range results {
+		items[i] = batchItem{Status: batchStatuses[batch.Operations[i].Op], ID: result.ID, Car: result.Car}
+		if result.Err != nil {
+			problem := newProblem(r, result.Err, utils.ErrUpdatingObject)
+			items[i] = batchItem{Status: problem.Status, ID: result.ID, Error: &problem}
+			status = http.StatusMultiStatus
+		}
+	}
+
+	if err := resp.write(w, status, items); err != nil {
+		log.Printf("error while encoding the batch results into the response body. err: %v\n", err)
+	}
+}
@@ -1,78 +1,116 @@
 package controller
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/DalvinCodes/cars/codec"
 	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/patch"
+	"github.com/DalvinCodes/cars/router"
 	"github.com/DalvinCodes/cars/service"
 	"github.com/DalvinCodes/cars/utils"
 )
 
+// carsPath is the collection resource all car routes hang off.
+const carsPath = "/api/v1/cars"
+
+const (
+	totalCountHeaderName = "X-Total-Count"
+	nextCursorHeaderName = "X-Next-Cursor"
+	prevCursorHeaderName = "X-Prev-Cursor"
+	actorHeaderName      = "X-Actor"
+)
+
 type CarController interface {
 	CreateCarHandler(w http.ResponseWriter, r *http.Request)
 	DeleteCarHandler(w http.ResponseWriter, r *http.Request)
 	GetCarHandler(w http.ResponseWriter, r *http.Request)
 	GetCarsHandler(w http.ResponseWriter, r *http.Request)
 	UpdateCarHandler(w http.ResponseWriter, r *http.Request)
+	PatchCarHandler(w http.ResponseWriter, r *http.Request)
+	RestoreCarHandler(w http.ResponseWriter, r *http.Request)
+	BatchCarsHandler(w http.ResponseWriter, r *http.Request)
+	GetCarRevisionsHandler(w http.ResponseWriter, r *http.Request)
+	GetCarRevisionHandler(w http.ResponseWriter, r *http.Request)
+	DiffCarRevisionsHandler(w http.ResponseWriter, r *http.Request)
+	ExportCarsHandler(w http.ResponseWriter, r *http.Request)
+	ImportCarsHandler(w http.ResponseWriter, r *http.Request)
+	SearchCarsHandler(w http.ResponseWriter, r *http.Request)
+	GetCarFacetsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type CarsController struct {
 	service service.CarService
+	codecs  *codec.Registry
 }
 
 func NewCarController(service service.CarService) *CarsController {
 	return &CarsController{
 		service: service,
+		codecs:  codec.Default(),
 	}
 }
 
 func (c *CarsController) CreateCarHandler(w http.ResponseWriter, r *http.Request) {
 	var car *model.Car
 
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
+	// pick how to encode the response before creating anything
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrCreatingObiect)
 		return
 	}
 
 	// decode the request body into the car struct
-	if err := json.NewDecoder(r.Body).Decode(&car); err != nil {
+	if err := c.decodeBody(r, &car); err != nil {
 		log.Printf("error while decoding the car data. err: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(utils.ErrCreatingObiect.Error()))
+		writeError(w, r, err, utils.ErrCreatingObiect)
 		return
 	}
 
+	// record who made the change
+	if car != nil {
+		car.UpdatedBy = r.Header.Get(actorHeaderName)
+	}
+
 	// create the car
 	if err := c.service.CreateCar(car); err != nil {
 		log.Printf("error while creating the car. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(utils.ErrCreatingObiect.Error()))
+		writeError(w, r, err, utils.ErrCreatingObiect)
 		return
 	}
 
-	// return the success status code
-	w.WriteHeader(http.StatusCreated)
-
+	// point the client at the new car and return it
+	w.Header().Set("Location", carsPath+"/"+car.ID)
+	w.Header().Set("ETag", etag(car, resp.mediaType))
+	if err := resp.write(w, http.StatusCreated, car); err != nil {
+		log.Printf("error while encoding the car into the response body. err: %v\n", err)
+	}
 }
 
 func (c *CarsController) DeleteCarHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
+	// get the car id from the url
+	id := carID(r)
+
+	// check the version the client expects to delete
+	version, err := c.requiredVersion(r, id)
+	if err != nil {
+		log.Printf("error while checking the car version. err: %v\n", err)
+		writeError(w, r, err, utils.ErrDeletingObject)
 		return
 	}
 
-	// get the car id from the url
-	id := r.URL.Query().Get("id")
-
 	// delete the car
-	if err := c.service.DeleteCar(id); err != nil {
+	if err := c.service.DeleteCarVersion(id, version, r.Header.Get(actorHeaderName)); err != nil {
 		log.Printf("error while deleting the car. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(utils.ErrDeletingObject.Error()))
+		writeError(w, r, err, utils.ErrDeletingObject)
 		return
 	}
 
@@ -81,88 +119,266 @@ func (c *CarsController) DeleteCarHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (c *CarsController) GetCarHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
+	// get the car id from the url
+	id := carID(r)
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
 		return
 	}
 
-	// get the car id from the url
-	id := r.URL.Query().Get("id")
-
-	// get the car
-	car, err := c.service.GetCar(id)
+	// get the car, or how it looked at the requested time
+	car, err := c.getCar(r, id)
 	if err != nil {
 		log.Printf("error while getting the car. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(utils.ErrRetrievingObject.Error()))
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// skip the body when the client already has this version
+	w.Header().Set("ETag", etag(car, resp.mediaType))
+	if header := r.Header.Get("If-None-Match"); header != "" && matchesWeak(header, etag(car, resp.mediaType)) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	// encode the car into the response body
-	if err := json.NewEncoder(w).Encode(car); err != nil {
+	if err := resp.write(w, http.StatusOK, car); err != nil {
 		log.Printf("error while encoding the car into the response body. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(utils.ErrRetrievingObject.Error()))
 		return
 	}
-
-	// return the success status code
-	w.WriteHeader(http.StatusOK)
 }
 
 func (c *CarsController) GetCarsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
+	// build the filters and sort order from the query string
+	query, err := parseCarQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the car query. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
 		return
 	}
 
-	// get all the cars
-	cars, err := c.service.GetCars()
+	// stream the whole listing when the client asks for NDJSON
+	resp, err := c.negotiate(w, r, ndjsonContentType)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+	if resp.mediaType == ndjsonContentType {
+		c.streamCars(w, r, query)
+		return
+	}
+
+	pageRequest, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the page request. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// get the requested page of matching cars
+	page, err := c.service.ListCars(query, pageRequest)
 	if err != nil {
 		log.Printf("error while getting the cars. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err, utils.ErrRetrievingObject)
 		return
 	}
 
+	// describe the surrounding pages in the headers
+	w.Header().Set(totalCountHeaderName, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeaderName, page.NextCursor)
+	}
+	if page.PrevCursor != "" {
+		w.Header().Set(prevCursorHeaderName, page.PrevCursor)
+	}
+	if links := pageLinks(r.URL, page); links != "" {
+		w.Header().Set("Link", links)
+	}
+
 	// encode the cars into the response body
-	if err := json.NewEncoder(w).Encode(cars); err != nil {
+	if err := resp.write(w, http.StatusOK, page.Cars); err != nil {
 		log.Printf("error while encoding the cars into the response body. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	// return the success status code
-	w.WriteHeader(http.StatusOK)
 }
 
 func (c *CarsController) UpdateCarHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
-		return
-	}
-
 	var car *model.Car
 
 	// get the car id from the url
-	id := r.URL.Query().Get("id")
+	id := carID(r)
 
 	// decode the request body into the car struct
-	if err := json.NewDecoder(r.Body).Decode(&car); err != nil {
+	if err := c.decodeBody(r, &car); err != nil {
 		log.Printf("error while decoding the car data. err: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err, utils.ErrUpdatingObject)
 		return
 	}
 
+	// an If-Match header wins over the version in the body
+	version, err := c.requiredVersion(r, id)
+	if err != nil {
+		log.Printf("error while checking the car version. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+	if car != nil {
+		if version != 0 {
+			car.Version = version
+		}
+		car.UpdatedBy = r.Header.Get(actorHeaderName)
+	}
+
 	// update the car
 	if err := c.service.UpdateCar(id, car); err != nil {
 		log.Printf("error while updating the car. err: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err, utils.ErrUpdatingObject)
 		return
 	}
 
-	// return the success status code
+	// return the success status code and the tag of the default, JSON,
+	// representation since there is no body to negotiate
+	w.Header().Set("ETag", etag(car, ""))
 	w.WriteHeader(http.StatusAccepted)
 }
+
+func (c *CarsController) PatchCarHandler(w http.ResponseWriter, r *http.Request) {
+	// get the car id from the url
+	id := carID(r)
+
+	// pick how to encode the response before patching anything
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// read the patch in the format named by the content type
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("error while reading the patch. err: %v\n", err)
+		writeError(w, r, fmt.Errorf("%w: %v", utils.ErrBadBody, err), utils.ErrUpdatingObject)
+		return
+	}
+
+	var p patch.Patch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchContentType:
+		p = patch.MergePatch(body)
+	case patch.JSONPatchContentType:
+		p, err = patch.ParseJSONPatch(body)
+	default:
+		err = fmt.Errorf("%w: use %s or %s", utils.ErrUnsupportedMediaType, patch.MergePatchContentType, patch.JSONPatchContentType)
+		w.Header().Set("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+	}
+	if err != nil {
+		log.Printf("error while parsing the patch. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// check the version the client expects to patch
+	version, err := c.requiredVersion(r, id)
+	if err != nil {
+		log.Printf("error while checking the car version. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// patch the car
+	car, err := c.service.PatchCar(id, version, r.Header.Get(actorHeaderName), p)
+	if err != nil {
+		log.Printf("error while patching the car. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// encode the patched car into the response body
+	w.Header().Set("ETag", etag(car, resp.mediaType))
+	if err := resp.write(w, http.StatusOK, car); err != nil {
+		log.Printf("error while encoding the car into the response body. err: %v\n", err)
+	}
+}
+
+func (c *CarsController) RestoreCarHandler(w http.ResponseWriter, r *http.Request) {
+	// get the car id from the url
+	id := carID(r)
+
+	// pick how to encode the response before restoring anything
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// check the version the client expects to restore
+	version, err := c.requiredVersion(r, id)
+	if err != nil {
+		log.Printf("error while checking the car version. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// restore the car
+	car, err := c.service.RestoreCar(id, version, r.Header.Get(actorHeaderName))
+	if err != nil {
+		log.Printf("error while restoring the car. err: %v\n", err)
+		writeError(w, r, err, utils.ErrUpdatingObject)
+		return
+	}
+
+	// encode the restored car into the response body
+	w.Header().Set("ETag", etag(car, resp.mediaType))
+	if err := resp.write(w, http.StatusOK, car); err != nil {
+		log.Printf("error while encoding the car into the response body. err: %v\n", err)
+	}
+}
+
+// getCar reads the current car, or the car as of the time in ?as_of=. Soft
+// deleted cars are only returned with ?include_deleted=true.
+func (c *CarsController) getCar(r *http.Request, id string) (*model.Car, error) {
+	asOf := r.URL.Query().Get("as_of")
+	if asOf == "" {
+		query, err := parseCarQuery(r.URL.Query())
+		if err != nil {
+			return nil, err
+		}
+		if query.IncludeDeleted {
+			return c.service.GetCarIncludingDeleted(id)
+		}
+		return c.service.GetCar(id)
+	}
+
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: as_of must be an RFC 3339 time", utils.ErrBadQuery)
+	}
+	return c.service.CarAsOf(id, at)
+}
+
+// NotFoundHandler answers requests for paths that no route serves.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, fmt.Errorf("%w: no resource at %s", utils.ErrNotFound, r.URL.Path), nil)
+}
+
+// MethodNotAllowedHandler answers requests whose method the route does not serve.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("error occured due to invalid request method. Method: %s\n", r.Method)
+	writeError(w, r, utils.ErrMethodNotAllowed, nil)
+}
+
+// carID reads the car id from the {id} path parameter, falling back to the
+// ?id= query parameter used by the legacy routes.
+func carID(r *http.Request) string {
+	if id := router.Param(r, "id"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("id")
+}
@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DalvinCodes/cars/controller"
 	"github.com/DalvinCodes/cars/model"
 	"github.com/DalvinCodes/cars/repository"
 	"github.com/DalvinCodes/cars/service"
 	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -199,3 +202,893 @@ func TestDeleteController(t *testing.T) {
 	})
 }
 
+func TestGetAllControllerFilters(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	civic := &model.Car{Make: "Honda", Model: "Civic", Color: "Red", Category: "Sedan", Year: 2019, Mileage: 12000, Price: 2000000}
+	accord := &model.Car{Make: "Honda", Model: "Accord", Color: "Blue", Category: "Sedan", Year: 2021, Mileage: 100, Price: 3000000}
+	camry := &model.Car{Make: "Toyota", Model: "Camry", Color: "Red", Category: "Sedan", Year: 2021, Mileage: 500, Price: 2800000}
+	for _, car := range []*model.Car{civic, accord, camry} {
+		_ = mockStorage.Save(car.Model, car)
+	}
+
+	t.Run("Filter and sort cars from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?year_min=2020&sort=-price", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		expectedCars, err := json.Marshal([]*model.Car{accord, camry})
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, string(expectedCars)+"\n", rr.Body.String())
+	})
+
+	t.Run("Reject invalid filters from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?price_max=cheap", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestGetAllControllerPagination(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	for _, id := range []string{"a", "b", "c"} {
+		_ = mockStorage.Save(id, &model.Car{ID: id, Make: "Honda"})
+	}
+
+	t.Run("Page through cars from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?limit=2", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var cars []*model.Car
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&cars))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Len(t, cars, 2)
+		assert.Equal(t, "3", rr.Header().Get("X-Total-Count"))
+		assert.NotEmpty(t, rr.Header().Get("X-Next-Cursor"))
+		assert.Contains(t, rr.Header().Get("Link"), `rel="next"`)
+
+		// When
+		next := rr.Header().Get("X-Next-Cursor")
+		rr = httptest.NewRecorder()
+		req, err = http.NewRequest("GET", "/cars?limit=2&cursor="+next, nil)
+		assert.NoError(t, err)
+
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		cars = nil
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&cars))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []*model.Car{{ID: "c", Make: "Honda", Version: 1}}, cars)
+		assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+		assert.NotEmpty(t, rr.Header().Get("X-Prev-Cursor"))
+	})
+
+	t.Run("Reject invalid limit from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?limit=-1", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestCreateControllerInvalid(t *testing.T) {
+	t.Run("Reject invalid car from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockStorage := repository.NewRepo()
+		mockService := service.NewCarService(mockStorage)
+		carController := controller.NewCarController(mockService)
+
+		body := []byte(`{"make":"","model":"Model 3","year":1200,"mileage":-5,"price":4500000}`)
+
+		// When
+		req, err := http.NewRequest("POST", "/cars", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.CreateCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var problem controller.Problem
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+		assert.Len(t, problem.Errors, 3)
+		assert.Equal(t, validator.FieldError{Field: "make", Rule: "required", Message: "is required"}, problem.Errors[0])
+	})
+}
+
+func TestGetControllerNotFound(t *testing.T) {
+	t.Run("Get missing car from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockStorage := repository.NewRepo()
+		mockService := service.NewCarService(mockStorage)
+		carController := controller.NewCarController(mockService)
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id=missing", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("Update missing car from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockStorage := repository.NewRepo()
+		mockService := service.NewCarService(mockStorage)
+		carController := controller.NewCarController(mockService)
+
+		body := []byte(`{"make":"Honda","model":"Civic","year":2019}`)
+
+		// When
+		req, err := http.NewRequest("PUT", "/cars?id=missing", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.UpdateCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Delete missing car from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockStorage := repository.NewRepo()
+		mockService := service.NewCarService(mockStorage)
+		carController := controller.NewCarController(mockService)
+
+		// When
+		req, err := http.NewRequest("DELETE", "/cars?id=missing", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.DeleteCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestPatchController(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	car := &model.Car{
+		Make:     "Chevrolet",
+		Model:    "Corvette",
+		Package:  "Stingray",
+		Color:    "Red",
+		Year:     2022,
+		Category: "Coupe",
+		Mileage:  99,
+		Price:    9900000,
+	}
+	_ = mockService.CreateCar(car)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"Merge patch", "application/merge-patch+json", `{"price":9500000}`, http.StatusOK},
+		{"JSON patch", "application/json-patch+json", `[{"op":"replace","path":"/color","value":"Black"}]`, http.StatusOK},
+		{"Failed test operation", "application/json-patch+json", `[{"op":"test","path":"/color","value":"Blue"}]`, http.StatusConflict},
+		{"Invalid result", "application/merge-patch+json", `{"make":""}`, http.StatusUnprocessableEntity},
+		{"Unsupported content type", "application/json", `{"price":1}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+
+			// When
+			req, err := http.NewRequest("PATCH", "/cars?id="+car.ID, bytes.NewBufferString(tt.body))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+
+			handler := http.HandlerFunc(carController.PatchCarHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+
+	object, err := mockService.GetCar(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 9500000, object.Price)
+	assert.Equal(t, "Black", object.Color)
+	assert.Equal(t, "Chevrolet", object.Make)
+}
+
+func TestConditionalRequestsController(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	car := &model.Car{Make: "Ford", Model: "Mustang", Color: "Blue", Year: 2021, Category: "Coupe", Price: 4000000}
+	assert.NoError(t, mockService.CreateCar(car))
+
+	t.Run("Get car returns its ETag", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id="+car.ID, nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"1"`, rr.Header().Get("ETag"))
+	})
+
+	t.Run("Get unchanged car with If-None-Match", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id="+car.ID, nil)
+		assert.NoError(t, err)
+		req.Header.Set("If-None-Match", `W/"1"`)
+
+		handler := http.HandlerFunc(carController.GetCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("Each representation has its own ETag", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id="+car.ID, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/yaml")
+		req.Header.Set("If-None-Match", `"1"`)
+
+		handler := http.HandlerFunc(carController.GetCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"1-yaml"`, rr.Header().Get("ETag"))
+	})
+
+	t.Run("Update car with a stale If-Match", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		body, err := json.Marshal(&model.Car{Make: "Ford", Model: "Mustang", Color: "Red", Year: 2021})
+		assert.NoError(t, err)
+
+		// When
+		req, err := http.NewRequest("PUT", "/cars?id="+car.ID, bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("If-Match", `"7"`)
+
+		handler := http.HandlerFunc(carController.UpdateCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("Update car with a matching If-Match", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		body, err := json.Marshal(&model.Car{Make: "Ford", Model: "Mustang", Color: "Red", Year: 2021})
+		assert.NoError(t, err)
+
+		// When
+		req, err := http.NewRequest("PUT", "/cars?id="+car.ID, bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("If-Match", `"0", "1"`)
+
+		handler := http.HandlerFunc(carController.UpdateCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+	})
+
+	t.Run("Patch car with the If-Match of another representation", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("PATCH", "/cars?id="+car.ID, bytes.NewBufferString(`{"price":3900000}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("Accept", "application/msgpack")
+		req.Header.Set("If-Match", `"2-yaml"`)
+
+		handler := http.HandlerFunc(carController.PatchCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"3-msgpack"`, rr.Header().Get("ETag"))
+	})
+
+	t.Run("Patch car with a weak If-Match", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("PATCH", "/cars?id="+car.ID, bytes.NewBufferString(`{"price":3900000}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", `W/"3"`)
+
+		handler := http.HandlerFunc(carController.PatchCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	})
+
+	t.Run("Delete car with a stale If-Match", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("DELETE", "/cars?id="+car.ID, nil)
+		assert.NoError(t, err)
+		req.Header.Set("If-Match", `"1"`)
+
+		handler := http.HandlerFunc(carController.DeleteCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+		object, err := mockService.GetCar(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Red", object.Color)
+	})
+}
+
+func TestHistoryController(t *testing.T) {
+	mockStorage := repository.NewHistoryStore(repository.NewRepo(), time.Now)
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	t.Run("Record the actor of a change", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		body := []byte(`{"make":"Honda","model":"Civic","year":2019}`)
+
+		// When
+		req, err := http.NewRequest("POST", "/cars", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("X-Actor", "alice")
+
+		handler := http.HandlerFunc(carController.CreateCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var car model.Car
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&car))
+
+		revisions, err := mockService.CarRevisions(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", revisions[0].Actor)
+	})
+
+	t.Run("Reject an invalid as_of time", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id=any&as_of=yesterday", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Reject a diff without revisions", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?id=any&from=1", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.DiffCarRevisionsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestSoftDeleteController(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	car := &model.Car{Make: "Mazda", Model: "MX-5", Year: 2022}
+	assert.NoError(t, mockService.CreateCar(car))
+	assert.NoError(t, mockService.DeleteCar(car.ID))
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		count  int
+	}{
+		{"Deleted cars are hidden", "/cars", http.StatusOK, 0},
+		{"Deleted cars are listed on request", "/cars?include_deleted=true", http.StatusOK, 1},
+		{"Reject invalid include_deleted", "/cars?include_deleted=maybe", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+
+			// When
+			req, err := http.NewRequest("GET", tt.url, nil)
+			assert.NoError(t, err)
+
+			handler := http.HandlerFunc(carController.GetCarsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusOK {
+				var cars []*model.Car
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&cars))
+				assert.Len(t, cars, tt.count)
+			}
+		})
+	}
+
+	t.Run("Restore car from controller", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+
+		// When
+		req, err := http.NewRequest("POST", "/cars?id="+car.ID, nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.RestoreCarHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		// restoring again conflicts
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
+
+func TestBatchController(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		statuses []int
+	}{
+		{
+			"Batch of successful operations",
+			`{"operations":[{"op":"create","car":{"make":"Honda","model":"Civic","year":2020}},{"op":"create","car":{"make":"Kia","model":"Soul","year":2021}}]}`,
+			http.StatusOK,
+			[]int{http.StatusCreated, http.StatusCreated},
+		},
+		{
+			"Batch with a failed operation",
+			`{"operations":[{"op":"create","car":{"make":"Honda","model":"Civic","year":2020}},{"op":"delete","id":"missing"}]}`,
+			http.StatusMultiStatus,
+			[]int{http.StatusCreated, http.StatusNotFound},
+		},
+		{
+			"Atomic batch with a failed operation",
+			`{"atomic":true,"operations":[{"op":"create","car":{"make":"Honda","model":"Civic","year":2020}},{"op":"delete","id":"missing"}]}`,
+			http.StatusMultiStatus,
+			[]int{http.StatusFailedDependency, http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mockStorage := repository.NewRepo()
+			mockService := service.NewCarService(mockStorage)
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("POST", "/cars/batch", bytes.NewBufferString(tt.body))
+			assert.NoError(t, err)
+
+			handler := http.HandlerFunc(carController.BatchCarsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			var items []struct {
+				Status int `json:"status"`
+			}
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&items))
+
+			assert.Equal(t, tt.status, rr.Code)
+			statuses := []int{}
+			for _, item := range items {
+				statuses = append(statuses, item.Status)
+			}
+			assert.Equal(t, tt.statuses, statuses)
+		})
+	}
+
+	t.Run("Reject an empty batch", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		carController := controller.NewCarController(service.NewCarService(repository.NewRepo()))
+
+		// When
+		req, err := http.NewRequest("POST", "/cars/batch", bytes.NewBufferString(`{"operations":[]}`))
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.BatchCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestStreamController(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		query       string
+		status      int
+		contentType string
+	}{
+		{"Stream when NDJSON is asked for", "application/x-ndjson", "", http.StatusOK, "application/x-ndjson"},
+		{"Stream when NDJSON is preferred", "application/json;q=0.5, application/x-ndjson", "", http.StatusOK, "application/x-ndjson"},
+		{"Keep JSON for any type", "*/*", "", http.StatusOK, "application/json"},
+		{"Keep JSON without an Accept header", "", "", http.StatusOK, "application/json"},
+		{"Reject paging a stream", "application/x-ndjson", "?limit=1", http.StatusBadRequest, "application/problem+json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mockService := service.NewCarService(repository.NewRepo())
+			for _, name := range []string{"Civic", "Accord", "Fit"} {
+				assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: name, Year: 2020}))
+			}
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("GET", "/cars"+tt.query, nil)
+			assert.NoError(t, err)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			handler := http.HandlerFunc(carController.GetCarsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+		})
+	}
+
+	t.Run("Stream one car per line in query order", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockService := service.NewCarService(repository.NewRepo())
+		for _, year := range []int{2021, 2019, 2020} {
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Year: year}))
+		}
+		carController := controller.NewCarController(mockService)
+
+		// When
+		req, err := http.NewRequest("GET", "/cars?sort=year", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		body := rr.Body.String()
+		assert.Equal(t, 3, strings.Count(body, "\n"))
+
+		years := []int{}
+		decoder := json.NewDecoder(strings.NewReader(body))
+		for decoder.More() {
+			var car model.Car
+			assert.NoError(t, decoder.Decode(&car))
+			years = append(years, car.Year)
+		}
+		assert.Equal(t, []int{2019, 2020, 2021}, years)
+	})
+}
+
+func TestContentNegotiationController(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		accept       string
+		body         string
+		status       int
+		responseType string
+		created      int
+	}{
+		{"JSON by default", "", "", `{"make":"Honda","model":"Civic","year":2019}`, http.StatusCreated, "application/json", 1},
+		{"XML in, YAML out", "application/xml", "application/yaml", `<car><make>Honda</make><model>Civic</model><year>2019</year></car>`, http.StatusCreated, "application/yaml", 1},
+		{"Answer in the alias asked for", "", "text/xml", `{"make":"Honda","model":"Civic","year":2019}`, http.StatusCreated, "text/xml", 1},
+		{"Reject an unacceptable response before creating", "", "text/html", `{"make":"Honda","model":"Civic","year":2019}`, http.StatusNotAcceptable, "application/problem+json", 0},
+		{"Reject an unsupported body", "text/plain", "", `make=Honda`, http.StatusUnsupportedMediaType, "application/problem+json", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mockService := service.NewCarService(repository.NewRepo())
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("POST", "/cars", bytes.NewBufferString(tt.body))
+			assert.NoError(t, err)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			handler := http.HandlerFunc(carController.CreateCarHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.responseType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+
+			cars, err := mockService.GetCars()
+			assert.NoError(t, err)
+			assert.Len(t, cars, tt.created)
+		})
+	}
+
+	t.Run("List cars as XML", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockService := service.NewCarService(repository.NewRepo())
+		assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Year: 2019}))
+		carController := controller.NewCarController(mockService)
+
+		// When
+		req, err := http.NewRequest("GET", "/cars", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/xml")
+
+		handler := http.HandlerFunc(carController.GetCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/xml", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "<cars>")
+		assert.Contains(t, rr.Body.String(), "<make>Honda</make>")
+	})
+}
+
+func TestSearchController(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		models []string
+	}{
+		{"Rank the best match first", "?q=red+civic+sport+2019", http.StatusOK, []string{"Civic", "Accord"}},
+		{"Match with a typo", "?q=acord", http.StatusOK, []string{"Accord"}},
+		{"Filter the hits", "?q=red&year_min=2020", http.StatusOK, []string{"Accord"}},
+		{"Limit the hits", "?q=honda&limit=1", http.StatusOK, []string{"Civic"}},
+		{"Reject a search without words", "?q=+", http.StatusBadRequest, nil},
+		{"Reject a bad limit", "?q=civic&limit=zero", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			store, err := repository.NewSearchStore(repository.NewRepo())
+			assert.NoError(t, err)
+			mockService := service.NewCarService(store)
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Package: "Sport", Color: "Red", Year: 2019}))
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Accord", Color: "Red", Year: 2021}))
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("GET", "/cars/search"+tt.query, nil)
+			assert.NoError(t, err)
+
+			handler := http.HandlerFunc(carController.SearchCarsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var hits []model.SearchHit
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&hits))
+			models := []string{}
+			for _, hit := range hits {
+				models = append(models, hit.Car.Model)
+			}
+			assert.Equal(t, tt.models, models)
+		})
+	}
+
+	t.Run("Report storage without an index", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		carController := controller.NewCarController(service.NewCarService(repository.NewRepo()))
+
+		// When
+		req, err := http.NewRequest("GET", "/cars/search?q=civic", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.SearchCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	})
+}
+
+func TestFacetsController(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		total  int
+		fields []string
+	}{
+		{"Every facet by default", "", http.StatusOK, 3, []string{"make", "color", "category", "year", "price", "mileage"}},
+		{"Chosen facets over the filtered cars", "?facets=make,price&price_bounds=2500000&year_min=2020", http.StatusOK, 2, []string{"make", "price"}},
+		{"Reject an unknown facet", "?facets=model", http.StatusBadRequest, 0, nil},
+		{"Reject bounds out of order", "?facets=year&year_bounds=2020,2010", http.StatusBadRequest, 0, nil},
+		{"Reject a bad facet size", "?facet_size=-1", http.StatusBadRequest, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mockService := service.NewCarService(repository.NewRepo())
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 2000000}))
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Toyota", Model: "Tacoma", Year: 2021, Price: 3500000}))
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Year: 2022, Price: 2200000}))
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("GET", "/cars/facets"+tt.query, nil)
+			assert.NoError(t, err)
+
+			handler := http.HandlerFunc(carController.GetCarFacetsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var facets model.Facets
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&facets))
+			assert.Equal(t, tt.total, facets.Total)
+			fields := []string{}
+			for _, facet := range facets.Facets {
+				fields = append(fields, facet.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+
+	t.Run("Count makes and price buckets", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockService := service.NewCarService(repository.NewRepo())
+		assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 2000000}))
+		assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Toyota", Model: "Tacoma", Year: 2021, Price: 3500000}))
+		assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Year: 2022, Price: 2200000}))
+		carController := controller.NewCarController(mockService)
+
+		// When
+		req, err := http.NewRequest("GET", "/cars/facets?facets=make,price&price_bounds=2100000,3000000", nil)
+		assert.NoError(t, err)
+
+		handler := http.HandlerFunc(carController.GetCarFacetsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var facets model.Facets
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&facets))
+		assert.Equal(t, []model.TermCount{{Value: "Toyota", Count: 2}, {Value: "Honda", Count: 1}}, facets.Facets[0].Terms)
+
+		counts := []int{}
+		for _, bucket := range facets.Facets[1].Ranges {
+			counts = append(counts, bucket.Count)
+		}
+		assert.Equal(t, []int{1, 1, 1}, counts)
+	})
+}
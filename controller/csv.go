@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DalvinCodes/cars/csvio"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+func (c *CarsController) ExportCarsHandler(w http.ResponseWriter, r *http.Request) {
+	// build the filters, columns and header option from the query string
+	query, err := parseCarQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the car query. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	columns, header, err := parseCSVOptions(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the CSV options. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	writer, err := csvio.NewWriter(w, columns, header)
+	if err != nil {
+		log.Printf("error while creating the CSV writer. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// write the matching cars as storage hands them over
+	w.Header().Set("Content-Type", csvio.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="cars.csv"`)
+	written := 0
+	err = c.service.StreamCars(query, func(car *model.Car) error {
+		written++
+		return writer.Write(car)
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Printf("error while writing the cars as CSV. err: %v\n", err)
+		// the status is only still ours to set if nothing was written
+		if written == 0 {
+			writeError(w, r, err, utils.ErrRetrievingObject)
+		}
+	}
+}
+
+func (c *CarsController) ImportCarsHandler(w http.ResponseWriter, r *http.Request) {
+	// pick how to encode the report before importing anything
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrCreatingObiect)
+		return
+	}
+
+	// find the CSV, either the whole body or the file of a form upload
+	body, err := csvBody(r)
+	if err != nil {
+		log.Printf("error while reading the CSV upload. err: %v\n", err)
+		writeError(w, r, err, utils.ErrCreatingObiect)
+		return
+	}
+
+	columns, header, err := parseCSVOptions(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the CSV options. err: %v\n", err)
+		writeError(w, r, err, utils.ErrCreatingObiect)
+		return
+	}
+
+	reader, err := csvio.NewReader(body, columns, header)
+	if err != nil {
+		log.Printf("error while reading the CSV header. err: %v\n", err)
+		writeError(w, r, err, utils.ErrCreatingObiect)
+		return
+	}
+
+	// save the cars row by row
+	actor := r.Header.Get(actorHeaderName)
+	report, err := csvio.Import(reader, func(car *model.Car) (bool, error) {
+		car.UpdatedBy = actor
+		return c.service.ImportCar(car)
+	})
+	if err != nil {
+		log.Printf("error while importing the cars. err: %v\n", err)
+		writeError(w, r, fmt.Errorf("%w: %v", utils.ErrBadBody, err), utils.ErrCreatingObiect)
+		return
+	}
+
+	// report the failed rows the way writeError reports errors, so internals
+	// never reach the client
+	for _, rowErr := range report.Errors {
+		if reported := clientError(rowErr.Err, utils.ErrCreatingObiect); reported != rowErr.Err {
+			log.Printf("error while importing line %d. err: %v\n", rowErr.Line, rowErr.Err)
+			rowErr.Err = reported
+		}
+	}
+
+	// encode the report into the response body
+	if err := resp.write(w, http.StatusOK, report); err != nil {
+		log.Printf("error while encoding the import report into the response body. err: %v\n", err)
+	}
+}
+
+// parseCSVOptions reads the columns and header parameters. The header is on
+// unless header=false.
+func parseCSVOptions(values url.Values) ([]string, bool, error) {
+	columns := csvio.ParseColumns(values.Get("columns"))
+
+	header := true
+	if raw := values.Get("header"); raw != "" {
+		var err error
+		if header, err = strconv.ParseBool(raw); err != nil {
+			return nil, false, fmt.Errorf("%w: header must be a boolean", utils.ErrBadQuery)
+		}
+	}
+	return columns, header, nil
+}
+
+// csvBody returns a stream over the uploaded CSV: the request body for
+// text/csv, or the first file of a multipart/form-data upload.
+func csvBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case csvio.ContentType:
+		return r.Body, nil
+	case "multipart/form-data":
+		parts, err := r.MultipartReader()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrBadBody, err)
+		}
+		for {
+			part, err := parts.NextPart()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%w: the upload holds no file", utils.ErrBadBody)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", utils.ErrBadBody, err)
+			}
+			if part.FileName() != "" {
+				return part, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: upload the CSV as %s or multipart/form-data", utils.ErrUnsupportedMediaType, csvio.ContentType)
+}
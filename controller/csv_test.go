@@ -0,0 +1,200 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DalvinCodes/cars/controller"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/service"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportController(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{
+			"Export the chosen columns of the matching cars",
+			"?make=Honda&columns=make,model,year",
+			http.StatusOK,
+			"make,model,year\nHonda,Civic,2019\n",
+		},
+		{
+			"Export without a header",
+			"?make=Honda&columns=model&header=false",
+			http.StatusOK,
+			"Civic\n",
+		},
+		{
+			"Reject an unknown column",
+			"?columns=make,wheels",
+			http.StatusBadRequest,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mockService := service.NewCarService(repository.NewRepo())
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Honda", Model: "Civic", Year: 2019}))
+			assert.NoError(t, mockService.CreateCar(&model.Car{Make: "Kia", Model: "Soul", Year: 2021}))
+			carController := controller.NewCarController(mockService)
+
+			// When
+			req, err := http.NewRequest("GET", "/cars/export"+tt.query, nil)
+			assert.NoError(t, err)
+
+			handler := http.HandlerFunc(carController.ExportCarsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestImportController(t *testing.T) {
+	t.Run("Import a CSV body and report the failed rows", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockService := service.NewCarService(repository.NewRepo())
+		carController := controller.NewCarController(mockService)
+		body := "make,model,year,price\nHonda,Civic,2019,2000000\n,Soul,2021,0\nKia,Rio,new,0\n"
+
+		// When
+		req, err := http.NewRequest("POST", "/cars/import", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "text/csv")
+
+		handler := http.HandlerFunc(carController.ImportCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var report struct {
+			Created int `json:"created"`
+			Failed  int `json:"failed"`
+			Errors  []struct {
+				Line int `json:"line"`
+			} `json:"errors"`
+		}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Failed)
+		if assert.Len(t, report.Errors, 2) {
+			assert.Equal(t, 3, report.Errors[0].Line)
+			assert.Equal(t, 4, report.Errors[1].Line)
+		}
+
+		cars, err := mockService.GetCars()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+	})
+
+	t.Run("Import a form upload", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		mockService := service.NewCarService(repository.NewRepo())
+		carController := controller.NewCarController(mockService)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, err := form.CreateFormFile("file", "cars.csv")
+		assert.NoError(t, err)
+		_, err = file.Write([]byte("Honda,Civic,2019\n"))
+		assert.NoError(t, err)
+		assert.NoError(t, form.Close())
+
+		// When
+		req, err := http.NewRequest("POST", "/cars/import?columns=make,model,year&header=false", &body)
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		handler := http.HandlerFunc(carController.ImportCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusOK, rr.Code)
+		cars, err := mockService.GetCars()
+		assert.NoError(t, err)
+		if assert.Len(t, cars, 1) {
+			assert.Equal(t, "Civic", cars[0].Model)
+		}
+	})
+
+	t.Run("Report rows that fail in the storage without its error", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		carController := controller.NewCarController(service.NewCarService(&failingStorage{Storage: repository.NewRepo()}))
+		body := "make,model,year\nHonda,Civic,2019\n"
+
+		// When
+		req, err := http.NewRequest("POST", "/cars/import", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "text/csv")
+
+		handler := http.HandlerFunc(carController.ImportCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		var report struct {
+			Failed int `json:"failed"`
+			Errors []struct {
+				Line  int    `json:"line"`
+				Error string `json:"error"`
+			} `json:"errors"`
+		}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 1, report.Failed)
+		if assert.Len(t, report.Errors, 1) {
+			assert.Equal(t, 2, report.Errors[0].Line)
+			assert.Equal(t, utils.ErrCreatingObiect.Error(), report.Errors[0].Error)
+		}
+	})
+
+	t.Run("Reject a body that is not CSV", func(t *testing.T) {
+		// Given
+		rr := httptest.NewRecorder()
+		carController := controller.NewCarController(service.NewCarService(repository.NewRepo()))
+
+		// When
+		req, err := http.NewRequest("POST", "/cars/import", bytes.NewBufferString(`{"make":"Honda"}`))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		handler := http.HandlerFunc(carController.ImportCarsHandler)
+		handler.ServeHTTP(rr, req)
+
+		// Then
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+}
+
+// failingStorage fails every transaction with an error that names its files.
+type failingStorage struct {
+	repository.Storage
+}
+
+func (s *failingStorage) Begin() (repository.Tx, error) {
+	return nil, errors.New("open /var/lib/cars/cars.wal: permission denied")
+}
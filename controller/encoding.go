@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/DalvinCodes/cars/codec"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// response is how a handler encodes its response body: as mediaType, with
+// codec, or by itself when codec is nil.
+type response struct {
+	mediaType string
+	codec     codec.Codec
+}
+
+// negotiate picks the response encoding the Accept header rates highest out of
+// the registered codecs and any extra media types the handler writes itself.
+// Handlers negotiate before changing anything, so a request for a type that
+// cannot be produced has no effect.
+func (c *CarsController) negotiate(w http.ResponseWriter, r *http.Request, extra ...string) (response, error) {
+	w.Header().Set("Vary", "Accept")
+
+	offers := append(c.codecs.MediaTypes(), extra...)
+	mediaType := codec.Negotiate(r.Header.Get("Accept"), offers...)
+	if mediaType == "" {
+		return response{}, fmt.Errorf("%w: responses can be %s", utils.ErrNotAcceptable, strings.Join(offers, ", "))
+	}
+	encoder, _ := c.codecs.Lookup(mediaType)
+	return response{mediaType: mediaType, codec: encoder}, nil
+}
+
+// write sends status and v encoded as the response's media type.
+func (resp response) write(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", resp.mediaType)
+	w.WriteHeader(status)
+	return resp.codec.Encode(w, v)
+}
+
+// decodeBody reads the request body into v with the codec its Content-Type
+// names, JSON when it names none.
+func (c *CarsController) decodeBody(r *http.Request, v any) error {
+	decoder, err := c.codecs.ForContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(r.Body, v); err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrBadBody, err)
+	}
+	return nil
+}
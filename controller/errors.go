@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/DalvinCodes/cars/codec"
+	"github.com/DalvinCodes/cars/middleware"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
+)
+
+// The RFC 7807 media types of problems encoded as JSON and as XML.
+const (
+	problemJSON = "application/problem+json"
+	problemXML  = "application/problem+xml"
+)
+
+// problemCodecs are the encodings a problem can be written in.
+var problemCodecs = codec.Default()
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string                 `json:"type" xml:"type"`
+	Title    string                 `json:"title" xml:"title"`
+	Status   int                    `json:"status" xml:"status"`
+	Detail   string                 `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty" xml:"instance,omitempty"`
+	TraceID  string                 `json:"trace_id,omitempty" xml:"trace_id,omitempty"`
+	Errors   []validator.FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// problemType describes how a domain error is reported to clients.
+type problemType struct {
+	err    error
+	uri    string
+	title  string
+	status int
+}
+
+var problemTypes = []problemType{
+	{utils.ErrNotFound, "/problems/not-found", "Not found", http.StatusNotFound},
+	{utils.ErrEmptyInput, "/problems/empty-input", "Empty input", http.StatusBadRequest},
+	{utils.ErrBadBody, "/problems/malformed-body", "Malformed request body", http.StatusBadRequest},
+	{utils.ErrBadQuery, "/problems/invalid-query", "Invalid query", http.StatusBadRequest},
+	{utils.ErrConflict, "/problems/conflict", "Conflict", http.StatusConflict},
+	{utils.ErrPreconditionFailed, "/problems/precondition-failed", "Precondition failed", http.StatusPreconditionFailed},
+	{utils.ErrUnprocessable, "/problems/unprocessable", "Unprocessable entity", http.StatusUnprocessableEntity},
+	{utils.ErrMethodNotAllowed, "about:blank", "Method Not Allowed", http.StatusMethodNotAllowed},
+	{utils.ErrUnsupportedMediaType, "about:blank", "Unsupported Media Type", http.StatusUnsupportedMediaType},
+	{utils.ErrNotAcceptable, "about:blank", "Not Acceptable", http.StatusNotAcceptable},
+	{utils.ErrAborted, "/problems/aborted", "Aborted", http.StatusFailedDependency},
+	{utils.ErrNotSupported, "/problems/not-supported", "Not supported", http.StatusNotImplemented},
+}
+
+// newProblem maps err to a problem. Errors with no mapping become a 500 whose
+// detail is the generic message of the failed operation, so internals never
+// reach the client.
+func newProblem(r *http.Request, err error, operation error) Problem {
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusInternalServerError),
+		Status:   http.StatusInternalServerError,
+		Instance: r.URL.Path,
+		TraceID:  traceID(r),
+	}
+	if operation != nil {
+		problem.Detail = operation.Error()
+	}
+
+	var invalid *validator.ValidationError
+	if errors.As(err, &invalid) {
+		problem.Type = "/problems/validation-error"
+		problem.Title = "Validation failed"
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Detail = "one or more fields are invalid"
+		problem.Errors = invalid.Errors
+		return problem
+	}
+
+	for _, pt := range problemTypes {
+		if errors.Is(err, pt.err) {
+			problem.Type = pt.uri
+			problem.Title = pt.title
+			problem.Status = pt.status
+			problem.Detail = err.Error()
+			return problem
+		}
+	}
+	return problem
+}
+
+// clientError returns err when it maps to a problem, whose detail is then err's
+// message, and operation otherwise, the way newProblem reports them.
+func clientError(err error, operation error) error {
+	var invalid *validator.ValidationError
+	if errors.As(err, &invalid) {
+		return err
+	}
+	for _, pt := range problemTypes {
+		if errors.Is(err, pt.err) {
+			return err
+		}
+	}
+	return operation
+}
+
+// writeError renders err as a problem in the encoding the Accept header
+// prefers. operation is the generic error reported when err has no specific
+// mapping, and may be nil.
+func writeError(w http.ResponseWriter, r *http.Request, err error, operation error) {
+	problem := newProblem(r, err, operation)
+
+	w.Header().Set("Vary", "Accept")
+	if err := problemEncoding(r).write(w, problem.Status, problem); err != nil {
+		log.Printf("error while encoding the problem into the response body. err: %v\n", err)
+	}
+}
+
+// problemEncoding picks how a problem is written for r. JSON and XML problems
+// have media types of their own; YAML and MessagePack ones are sent as their
+// codec's. A client that accepts none of them, such as one refused with a 406,
+// is still told what went wrong, in JSON.
+func problemEncoding(r *http.Request) response {
+	offers := append([]string{problemJSON, problemXML}, problemCodecs.MediaTypes()...)
+	mediaType := codec.Negotiate(r.Header.Get("Accept"), offers...)
+
+	encoder, ok := problemCodecs.Lookup(mediaType)
+	switch {
+	case mediaType == problemXML || ok && encoder == codec.XML:
+		return response{mediaType: problemXML, codec: codec.XML}
+	case mediaType == problemJSON || !ok || encoder == codec.JSON:
+		return response{mediaType: problemJSON, codec: codec.JSON}
+	}
+	return response{mediaType: mediaType, codec: encoder}
+}
+
+// traceID prefers the ID LoggingMiddleware put in the context and falls back
+// to the request header.
+func traceID(r *http.Request) string {
+	if id := middleware.TraceID(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Trace-ID")
+}
@@ -0,0 +1,111 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DalvinCodes/cars/codec"
+	"github.com/DalvinCodes/cars/controller"
+	"github.com/DalvinCodes/cars/middleware"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemResponses(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		handler http.HandlerFunc
+		status  int
+		typ     string
+	}{
+		{"Wrong method", "PATCH", "/cars", "", controller.MethodNotAllowedHandler, http.StatusMethodNotAllowed, "about:blank"},
+		{"Malformed body", "POST", "/cars", "{", carController.CreateCarHandler, http.StatusBadRequest, "/problems/malformed-body"},
+		{"Empty body", "POST", "/cars", "null", carController.CreateCarHandler, http.StatusBadRequest, "/problems/empty-input"},
+		{"Unknown route", "GET", "/cars", "", controller.NotFoundHandler, http.StatusNotFound, "/problems/not-found"},
+		{"Invalid query", "GET", "/cars?sort=horsepower", "", carController.GetCarsHandler, http.StatusBadRequest, "/problems/invalid-query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+
+			// When
+			req, err := http.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			assert.NoError(t, err)
+			req.Header.Set("X-Trace-ID", "trace-123")
+
+			handler := middleware.LoggingMiddleware(tt.handler)
+			handler.ServeHTTP(rr, req)
+
+			// Then
+			var problem controller.Problem
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.typ, problem.Type)
+			assert.Equal(t, "/cars", problem.Instance)
+			assert.Equal(t, "trace-123", problem.TraceID)
+		})
+	}
+}
+
+func TestProblemEncodings(t *testing.T) {
+	mockStorage := repository.NewRepo()
+	mockService := service.NewCarService(mockStorage)
+	carController := controller.NewCarController(mockService)
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		codec       codec.Codec
+	}{
+		{"No Accept header", "", "application/problem+json", codec.JSON},
+		{"JSON", "application/json", "application/problem+json", codec.JSON},
+		{"Problem JSON", "application/problem+json", "application/problem+json", codec.JSON},
+		{"XML", "application/xml", "application/problem+xml", codec.XML},
+		{"Problem XML", "application/problem+xml", "application/problem+xml", codec.XML},
+		{"YAML", "application/yaml", "application/yaml", codec.YAML},
+		{"MessagePack", "application/msgpack", "application/msgpack", codec.MessagePack},
+		{"Nothing a problem can be written in", "text/csv", "application/problem+json", codec.JSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+
+			// When
+			req, err := http.NewRequest("GET", "/cars?sort=horsepower", nil)
+			assert.NoError(t, err)
+			req.Header.Set("Accept", tt.accept)
+
+			carController.GetCarsHandler(rr, req)
+
+			// Then
+			var problem controller.Problem
+			assert.NoError(t, tt.codec.Decode(rr.Body, &problem))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+			assert.Equal(t, http.StatusBadRequest, problem.Status)
+			assert.Equal(t, "/problems/invalid-query", problem.Type)
+			assert.Equal(t, "/cars", problem.Instance)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// etag is the strong entity tag of a car encoded as mediaType. The version
+// tells states of the car apart. Strong tags must also differ between
+// representations, so every encoding but JSON, which keeps the bare version,
+// adds its name: "3" for JSON, "3-yaml" for YAML.
+func etag(car *model.Car, mediaType string) string {
+	tag := strconv.FormatInt(car.Version, 10)
+	if name := encodingName(mediaType); name != "" {
+		tag += "-" + name
+	}
+	return `"` + tag + `"`
+}
+
+// encodingName names the encoding of mediaType in an entity tag: its subtype
+// without an x- or vnd. prefix, or nothing for JSON.
+func encodingName(mediaType string) string {
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	subtype = strings.TrimPrefix(strings.TrimPrefix(subtype, "x-"), "vnd.")
+	if subtype == "json" {
+		return ""
+	}
+	return subtype
+}
+
+// matchesVersion reports whether an If-Match header matches the car at version
+// in any of its representations. Weak tags never match under the strong
+// comparison.
+func matchesVersion(header string, version int64) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if len(candidate) < 2 || candidate[0] != '"' || candidate[len(candidate)-1] != '"' {
+			continue
+		}
+		number, _, _ := strings.Cut(candidate[1:len(candidate)-1], "-")
+		if number == strconv.FormatInt(version, 10) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeak reports whether an If-None-Match header matches tag, ignoring
+// the weakness indicator.
+func matchesWeak(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredVersion checks the If-Match header of a write against the current
+// car and returns the version the write must replace, or 0 when the request
+// has no If-Match header.
+func (c *CarsController) requiredVersion(r *http.Request, id string) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	car, err := c.service.GetCarIncludingDeleted(id)
+	if errors.Is(err, utils.ErrNotFound) {
+		return 0, fmt.Errorf("%w: car %s does not exist", utils.ErrPreconditionFailed, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !matchesVersion(header, car.Version) {
+		return 0, fmt.Errorf("%w: car is at %s", utils.ErrPreconditionFailed, etag(car, ""))
+	}
+	return car.Version, nil
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/DalvinCodes/cars/utils"
+)
+
+func (c *CarsController) GetCarFacetsHandler(w http.ResponseWriter, r *http.Request) {
+	// build the filters and the facets to count from the query string
+	query, err := parseCarQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the car query. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	specs, err := parseFacetSpecs(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the facets. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// count the matching cars
+	facets, err := c.service.CarFacets(query, specs)
+	if err != nil {
+		log.Printf("error while counting the car facets. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// encode the facets into the response body
+	if err := resp.write(w, http.StatusOK, facets); err != nil {
+		log.Printf("error while encoding the facets into the response body. err: %v\n", err)
+	}
+}
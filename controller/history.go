@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DalvinCodes/cars/router"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+func (c *CarsController) GetCarRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	// get the car id from the url
+	id := carID(r)
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// get the revisions of the car
+	revisions, err := c.service.CarRevisions(id)
+	if err != nil {
+		log.Printf("error while getting the car revisions. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// encode the revisions into the response body
+	if err := resp.write(w, http.StatusOK, revisions); err != nil {
+		log.Printf("error while encoding the revisions into the response body. err: %v\n", err)
+	}
+}
+
+func (c *CarsController) GetCarRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	// get the car id and revision number from the url
+	id := carID(r)
+	number, err := revisionNumber(router.Param(r, "revision"))
+	if err != nil {
+		log.Printf("error while parsing the revision number. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// get the revision
+	revision, err := c.service.CarRevision(id, number)
+	if err != nil {
+		log.Printf("error while getting the car revision. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// encode the revision into the response body
+	if err := resp.write(w, http.StatusOK, revision); err != nil {
+		log.Printf("error while encoding the revision into the response body. err: %v\n", err)
+	}
+}
+
+func (c *CarsController) DiffCarRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	// get the car id from the url and the revisions to compare from the query
+	id := carID(r)
+	from, to, err := diffRange(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the revisions to diff. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// compare the revisions
+	changes, err := c.service.DiffCarRevisions(id, from, to)
+	if err != nil {
+		log.Printf("error while diffing the car revisions. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// encode the changes into the response body
+	if err := resp.write(w, http.StatusOK, changes); err != nil {
+		log.Printf("error while encoding the changes into the response body. err: %v\n", err)
+	}
+}
+
+func revisionNumber(raw string) (int, error) {
+	number, err := strconv.Atoi(raw)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%w: revision must be a positive integer, got %q", utils.ErrBadQuery, raw)
+	}
+	return number, nil
+}
+
+// diffRange reads the required ?from= and ?to= revision numbers.
+func diffRange(values url.Values) (int, int, error) {
+	from, err := revisionNumber(values.Get("from"))
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := revisionNumber(values.Get("to"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// parsePageRequest reads the limit and cursor parameters of a request.
+func parsePageRequest(values url.Values) (model.PageRequest, error) {
+	page := model.PageRequest{
+		Cursor: values.Get("cursor"),
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return model.PageRequest{}, fmt.Errorf("%w: limit must be a positive integer", utils.ErrBadQuery)
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// pageLinks builds a Link header pointing at the pages around page.
+func pageLinks(u *url.URL, page *model.Page) string {
+	var links []string
+	add := func(cursor, rel string) {
+		if cursor == "" {
+			return
+		}
+		values := u.Query()
+		values.Set("cursor", cursor)
+		link := *u
+		link.RawQuery = values.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, link.RequestURI(), rel))
+	}
+
+	add(page.NextCursor, "next")
+	add(page.PrevCursor, "prev")
+	return strings.Join(links, ", ")
+}
+
+// parseFacetSpecs reads which facets to count from ?facets=, all of them by
+// default, the number of values terms facets list from ?facet_size= and the
+// bucket bounds of each range facet from ?<field>_bounds=.
+func parseFacetSpecs(values url.Values) ([]model.FacetSpec, error) {
+	fields := append(append([]string(nil), model.TermsFacetFields...), model.RangeFacetFields...)
+	if raw := values.Get("facets"); raw != "" {
+		fields = strings.Split(raw, ",")
+	}
+
+	size := model.DefaultFacetSize
+	if raw := values.Get("facet_size"); raw != "" {
+		var err error
+		if size, err = strconv.Atoi(raw); err != nil || size < 0 {
+			return nil, fmt.Errorf("%w: facet_size must be a non-negative integer", utils.ErrBadQuery)
+		}
+	}
+
+	specs := make([]model.FacetSpec, 0, len(fields))
+	for _, field := range fields {
+		spec := model.FacetSpec{Field: strings.TrimSpace(field)}
+		switch {
+		case model.IsTermsFacet(spec.Field):
+			spec.Size = size
+		case model.IsRangeFacet(spec.Field):
+			param := spec.Field + "_bounds"
+			raw := values.Get(param)
+			if raw == "" {
+				spec.Bounds = model.DefaultFacetBounds[spec.Field]
+				break
+			}
+			for _, part := range strings.Split(raw, ",") {
+				bound, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s must be a comma separated list of integers", utils.ErrBadQuery, param)
+				}
+				spec.Bounds = append(spec.Bounds, bound)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown facet %q", utils.ErrBadQuery, spec.Field)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// parseCarQuery builds a model.Query from the filter and sort parameters of a request.
+func parseCarQuery(values url.Values) (model.Query, error) {
+	query := model.Query{
+		Make:     values.Get("make"),
+		Model:    values.Get("model"),
+		Color:    values.Get("color"),
+		Category: values.Get("category"),
+	}
+
+	bounds := []struct {
+		param string
+		dest  **int
+	}{
+		{"year_min", &query.YearMin},
+		{"year_max", &query.YearMax},
+		{"price_min", &query.PriceMin},
+		{"price_max", &query.PriceMax},
+		{"mileage_max", &query.MileageMax},
+	}
+	for _, bound := range bounds {
+		raw := values.Get(bound.param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return model.Query{}, fmt.Errorf("%w: %s must be an integer", utils.ErrBadQuery, bound.param)
+		}
+		*bound.dest = &value
+	}
+
+	if raw := values.Get("include_deleted"); raw != "" {
+		includeDeleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			return model.Query{}, fmt.Errorf("%w: include_deleted must be a boolean", utils.ErrBadQuery)
+		}
+		query.IncludeDeleted = includeDeleted
+	}
+
+	sort, err := model.ParseSort(values.Get("sort"))
+	if err != nil {
+		return model.Query{}, fmt.Errorf("%w: %v", utils.ErrBadQuery, err)
+	}
+	query.Sort = sort
+
+	return query, nil
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/DalvinCodes/cars/utils"
+)
+
+func (c *CarsController) SearchCarsHandler(w http.ResponseWriter, r *http.Request) {
+	// read the search text, filters and limit from the query string
+	text := r.URL.Query().Get("q")
+
+	query, err := parseCarQuery(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the car query. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		log.Printf("error while parsing the search limit. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	resp, err := c.negotiate(w, r)
+	if err != nil {
+		log.Printf("error while negotiating the response type. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// find the cars, the most relevant first
+	hits, err := c.service.SearchCars(text, query, page.Limit)
+	if err != nil {
+		log.Printf("error while searching the cars. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	// encode the hits into the response body
+	if err := resp.write(w, http.StatusOK, hits); err != nil {
+		log.Printf("error while encoding the search hits into the response body. err: %v\n", err)
+	}
+}
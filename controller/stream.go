@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// ndjsonContentType is newline delimited JSON: one car per line.
+const ndjsonContentType = "application/x-ndjson"
+
+// streamFlushEvery is how many cars are written between flushes of a stream.
+const streamFlushEvery = 100
+
+// streamCars writes every car matching query as NDJSON straight from storage,
+// so the listing is never held in memory as a whole.
+func (c *CarsController) streamCars(w http.ResponseWriter, r *http.Request, query model.Query) {
+	// a stream holds the whole listing, so there are no pages to ask for
+	values := r.URL.Query()
+	if values.Has("limit") || values.Has("cursor") {
+		err := fmt.Errorf("%w: limit and cursor do not apply to %s", utils.ErrBadQuery, ndjsonContentType)
+		log.Printf("error while parsing the page request. err: %v\n", err)
+		writeError(w, r, err, utils.ErrRetrievingObject)
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	encoder := json.NewEncoder(w)
+	flusher := http.NewResponseController(w)
+
+	// write the cars as storage hands them over, flushing now and then so the
+	// client can start on them
+	written := 0
+	err := c.service.StreamCars(query, func(car *model.Car) error {
+		if err := encoder.Encode(car); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushEvery == 0 {
+			if err := flusher.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("error while streaming the cars. err: %v\n", err)
+		// the status is only still ours to set if nothing was written
+		if written == 0 {
+			writeError(w, r, err, utils.ErrRetrievingObject)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package csvio reads and writes cars as CSV, one car per row, for the
+// spreadsheets the sales team works in. Columns are named after the JSON
+// fields of model.Car.
+package csvio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
+)
+
+const ContentType = "text/csv"
+
+// DefaultColumns are exported when no columns are asked for.
+var DefaultColumns = []string{"id", "make", "model", "package", "color", "category", "year", "mileage", "price"}
+
+// column reads and writes one field of a car as text.
+type column struct {
+	name string
+	get  func(car *model.Car) string
+	set  func(car *model.Car, value string) error
+}
+
+var columns = []column{
+	stringColumn("id", func(c *model.Car) *string { return &c.ID }),
+	stringColumn("make", func(c *model.Car) *string { return &c.Make }),
+	stringColumn("model", func(c *model.Car) *string { return &c.Model }),
+	stringColumn("package", func(c *model.Car) *string { return &c.Package }),
+	stringColumn("color", func(c *model.Car) *string { return &c.Color }),
+	stringColumn("category", func(c *model.Car) *string { return &c.Category }),
+	intColumn("year", func(c *model.Car) *int { return &c.Year }),
+	intColumn("mileage", func(c *model.Car) *int { return &c.Mileage }),
+	intColumn("price", func(c *model.Car) *int { return &c.Price }),
+	{
+		name: "version",
+		get:  func(c *model.Car) string { return strconv.FormatInt(c.Version, 10) },
+		set: func(c *model.Car, value string) error {
+			if value == "" {
+				return nil
+			}
+			version, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.New("must be an integer")
+			}
+			c.Version = version
+			return nil
+		},
+	},
+	stringColumn("updated_by", func(c *model.Car) *string { return &c.UpdatedBy }),
+}
+
+func stringColumn(name string, field func(*model.Car) *string) column {
+	return column{
+		name: name,
+		get:  func(c *model.Car) string { return *field(c) },
+		set: func(c *model.Car, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func intColumn(name string, field func(*model.Car) *int) column {
+	return column{
+		name: name,
+		get:  func(c *model.Car) string { return strconv.Itoa(*field(c)) },
+		set: func(c *model.Car, value string) error {
+			if value == "" {
+				return nil
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("must be an integer")
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+// ParseColumns splits a comma separated list of column names. Empty names are
+// kept, so a reader can skip the column at that position. An empty list parses
+// to nil, which selects the default columns.
+func ParseColumns(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	names := strings.Split(s, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+// lookup finds the columns with the given names, case-insensitively and with
+// spaces read as underscores. Empty names map to nil when skip is set.
+func lookup(names []string, skip bool) ([]*column, error) {
+	found := make([]*column, len(names))
+	for i, name := range names {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		if key == "" && skip {
+			continue
+		}
+		for j := range columns {
+			if columns[j].name == key {
+				found[i] = &columns[j]
+				break
+			}
+		}
+		if found[i] == nil {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	return found, nil
+}
+
+// Writer writes cars as CSV rows.
+type Writer struct {
+	csv     *csv.Writer
+	columns []*column
+	header  bool
+}
+
+// NewWriter writes the given columns of every car to w, headed by a row of
+// column names when header is set. An unknown column is reported as
+// utils.ErrBadQuery.
+func NewWriter(w io.Writer, names []string, header bool) (*Writer, error) {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+	found, err := lookup(names, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrBadQuery, err)
+	}
+
+	return &Writer{
+		csv:     csv.NewWriter(w),
+		columns: found,
+		header:  header,
+	}, nil
+}
+
+func (w *Writer) Write(car *model.Car) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		record[i] = col.get(car)
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes any buffered rows, and the header if no row has been written.
+func (w *Writer) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// writeHeader writes the header row once, just before the first row, so
+// nothing reaches the output until there is something to write.
+func (w *Writer) writeHeader() error {
+	if !w.header {
+		return nil
+	}
+	w.header = false
+
+	names := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		names[i] = col.name
+	}
+	return w.csv.Write(names)
+}
+
+// RowError is a row that could not be read or saved.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.body())
+}
+
+func (e *RowError) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(e.body(), start)
+}
+
+// rowErrorBody is how a RowError is reported to clients.
+type rowErrorBody struct {
+	Line   int                    `json:"line" xml:"line"`
+	Error  string                 `json:"error" xml:"error"`
+	Fields []validator.FieldError `json:"fields,omitempty" xml:"fields>field,omitempty"`
+}
+
+func (e *RowError) body() rowErrorBody {
+	body := rowErrorBody{
+		Line:  e.Line,
+		Error: e.Err.Error(),
+	}
+
+	var invalid *validator.ValidationError
+	if errors.As(e.Err, &invalid) {
+		body.Fields = invalid.Errors
+	}
+	return body
+}
+
+// Reader reads cars from CSV rows one at a time.
+type Reader struct {
+	csv     *csv.Reader
+	columns []*column
+}
+
+// NewReader reads cars from r. names gives the field of each column, with an
+// empty name for a column to skip; header says whether the first row holds
+// column names. Without names the header row is required and maps the columns.
+// A header or names that do not map to fields are reported as utils.ErrBadBody.
+func NewReader(r io.Reader, names []string, header bool) (*Reader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	if header {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: the CSV is empty", utils.ErrBadBody)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading the header: %v", utils.ErrBadBody, err)
+		}
+		if len(names) == 0 {
+			names = append([]string(nil), row...)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: the columns must be named by a header row or explicitly", utils.ErrBadBody)
+	}
+
+	found, err := lookup(names, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrBadBody, err)
+	}
+
+	return &Reader{
+		csv:     reader,
+		columns: found,
+	}, nil
+}
+
+// Read returns the car in the next row, or io.EOF after the last. A row that
+// cannot be read is reported as a *RowError, after which reading can go on.
+func (r *Reader) Read() (*model.Car, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &RowError{Line: parseErr.Line, Err: fmt.Errorf("%w: %v", utils.ErrBadBody, parseErr.Err)}
+		}
+		return nil, err
+	}
+	line, _ := r.csv.FieldPos(0)
+
+	if len(record) != len(r.columns) {
+		return nil, &RowError{Line: line, Err: fmt.Errorf("%w: expected %d columns, got %d", utils.ErrBadBody, len(r.columns), len(record))}
+	}
+
+	car := &model.Car{}
+	for i, col := range r.columns {
+		if col == nil {
+			continue
+		}
+		if err := col.set(car, strings.TrimSpace(record[i])); err != nil {
+			return nil, &RowError{Line: line, Err: fmt.Errorf("%w: %s %v", utils.ErrUnprocessable, col.name, err)}
+		}
+	}
+	return car, nil
+}
+
+// MaxReportedErrors caps the row errors kept in a Report.
+const MaxReportedErrors = 100
+
+// Report sums up an import.
+type Report struct {
+	Created int         `json:"created" xml:"created"`
+	Updated int         `json:"updated" xml:"updated"`
+	Failed  int         `json:"failed" xml:"failed"`
+	Errors  []*RowError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// Import reads every row of r and hands the car to save, which reports whether
+// it created a new car or updated one. Rows that cannot be read or saved are
+// counted, and the first MaxReportedErrors of them kept, without stopping the
+// import. Only an error reading the input stops it.
+func Import(r *Reader, save func(car *model.Car) (bool, error)) (*Report, error) {
+	report := &Report{}
+	fail := func(err *RowError) {
+		report.Failed++
+		if len(report.Errors) < MaxReportedErrors {
+			report.Errors = append(report.Errors, err)
+		}
+	}
+
+	for {
+		car, err := r.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			fail(rowErr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		line, _ := r.csv.FieldPos(0)
+		created, err := save(car)
+		switch {
+		case err != nil:
+			fail(&RowError{Line: line, Err: err})
+		case created:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+}
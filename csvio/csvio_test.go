@@ -0,0 +1,118 @@
+package csvio_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DalvinCodes/cars/csvio"
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	cars := []*model.Car{
+		{ID: "civic", Make: "Honda", Model: "Civic", Package: "Sport, Touring", Year: 2019, Price: 2000000},
+		{ID: "camry", Make: "Toyota", Model: "Camry", Year: 2020, Mileage: 100},
+	}
+
+	t.Run("Writes the default columns with a header", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := csvio.NewWriter(&buf, nil, true)
+		assert.NoError(t, err)
+		for _, car := range cars {
+			assert.NoError(t, w.Write(car))
+		}
+		assert.NoError(t, w.Flush())
+
+		assert.Equal(t, "id,make,model,package,color,category,year,mileage,price\n"+
+			"civic,Honda,Civic,\"Sport, Touring\",,,2019,0,2000000\n"+
+			"camry,Toyota,Camry,,,,2020,100,0\n", buf.String())
+	})
+
+	t.Run("Writes the chosen columns without a header", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := csvio.NewWriter(&buf, csvio.ParseColumns("Make, year"), false)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Write(cars[0]))
+		assert.NoError(t, w.Flush())
+
+		assert.Equal(t, "Honda,2019\n", buf.String())
+	})
+
+	t.Run("Writes only the header when there are no cars", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := csvio.NewWriter(&buf, []string{"id"}, true)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Flush())
+		assert.Equal(t, "id\n", buf.String())
+	})
+
+	t.Run("Rejects unknown columns", func(t *testing.T) {
+		_, err := csvio.NewWriter(&bytes.Buffer{}, []string{"make", "horsepower"}, true)
+		assert.ErrorIs(t, err, utils.ErrBadQuery)
+	})
+}
+
+func TestImport(t *testing.T) {
+	input := strings.Join([]string{
+		"Make,Model,Year,Price,Notes",
+		"Honda,Civic,2019,2000000,clean",
+		"Toyota,Camry,twenty,0,",
+		"Ford,,2021,1,",
+		"Kia,Soul,2021",
+		"Mazda,MX-5,2022,3000000,",
+	}, "\n")
+
+	t.Run("Maps the header and reports bad rows", func(t *testing.T) {
+		r, err := csvio.NewReader(strings.NewReader(input), csvio.ParseColumns("make,model,year,price,"), true)
+		assert.NoError(t, err)
+
+		var saved []*model.Car
+		report, err := csvio.Import(r, func(car *model.Car) (bool, error) {
+			if err := validator.Validate(car); err != nil {
+				return false, err
+			}
+			saved = append(saved, car)
+			return true, nil
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 3, report.Failed)
+		assert.Equal(t, []int{3, 4, 5}, []int{report.Errors[0].Line, report.Errors[1].Line, report.Errors[2].Line})
+		assert.ErrorIs(t, report.Errors[0], utils.ErrUnprocessable)
+		var invalid *validator.ValidationError
+		assert.True(t, errors.As(report.Errors[1], &invalid))
+		assert.ErrorIs(t, report.Errors[2], utils.ErrBadBody)
+
+		assert.Equal(t, "Honda", saved[0].Make)
+		assert.Equal(t, 2000000, saved[0].Price)
+		assert.Equal(t, "MX-5", saved[1].Model)
+	})
+
+	t.Run("Rejects an unknown header", func(t *testing.T) {
+		_, err := csvio.NewReader(strings.NewReader(input), nil, true)
+		assert.ErrorIs(t, err, utils.ErrBadBody)
+	})
+
+	t.Run("Needs the columns named somehow", func(t *testing.T) {
+		_, err := csvio.NewReader(strings.NewReader("Honda,Civic\n"), nil, false)
+		assert.ErrorIs(t, err, utils.ErrBadBody)
+	})
+
+	t.Run("Reads rows after a malformed one", func(t *testing.T) {
+		r, err := csvio.NewReader(strings.NewReader("make,model\nHo\"nda,Civic\nKia,Soul\n"), nil, true)
+		assert.NoError(t, err)
+
+		_, err = r.Read()
+		assert.ErrorIs(t, err, utils.ErrBadBody)
+
+		car, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, "Kia", car.Make)
+	})
+}
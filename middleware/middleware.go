@@ -11,6 +11,16 @@ import (
 
 const traceIDHeaderName = "X-Trace-ID"
 
+type contextKey string
+
+const traceIDKey contextKey = "traceID"
+
+// TraceID returns the trace ID LoggingMiddleware stored in ctx, if any.
+func TraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey).(string)
+	return traceID
+}
+
 func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -28,13 +38,13 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		w.Header().Set(traceIDHeaderName, traceID)
 
 		// create a context with the trace ID value
-		ctx := context.WithValue(r.Context(), traceIDHeaderName, traceID)
+		ctx := context.WithValue(r.Context(), traceIDKey, traceID)
 
 		// call the next handler with the updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
 
 		// extract the trace ID from the context
-		traceID = ctx.Value(traceIDHeaderName).(string)
+		traceID = TraceID(ctx)
 
 		// log the request details, including the trace ID
 		log.Printf("Method: %s URI: %s  TraceID: %s Latency: %s IPAddress: %s", r.Method, r.RequestURI, traceID, time.Since(start), r.RemoteAddr)
@@ -44,8 +54,9 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 func AddHeaders(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, X-Actor")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Link, X-Total-Count, X-Next-Cursor, X-Prev-Cursor, X-Trace-ID")
 
 		next(w, r)
 	}
@@ -0,0 +1,30 @@
+package model
+
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// MaxBatchSize caps the number of operations in one batch.
+const MaxBatchSize = 1000
+
+// BatchOperation is one write in a batch. Create needs Car, update needs ID
+// and Car, and delete needs ID. A non-zero Version must match the stored car.
+type BatchOperation struct {
+	Op      string `json:"op" xml:"op"`
+	ID      string `json:"id,omitempty" xml:"id,omitempty"`
+	Version int64  `json:"version,omitempty" xml:"version,omitempty"`
+	Car     *Car   `json:"car,omitempty" xml:"car,omitempty"`
+
+	// Actor is who asked for the write.
+	Actor string `json:"-" xml:"-"`
+}
+
+// BatchResult is the outcome of the operation at the same index. Car is the
+// stored car after a successful create or update.
+type BatchResult struct {
+	ID  string
+	Car *Car
+	Err error
+}
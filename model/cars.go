@@ -1,13 +1,36 @@
 package model
 
+import "time"
+
 type Car struct {
-	ID       string `json:"id"`
-	Make     string `json:"make"`
-	Model    string `json:"model"`
-	Package  string `json:"package"`
-	Color    string `json:"color"`
-	Category string `json:"category"`
-	Year     int    `json:"year"`
-	Mileage  int    `json:"mileage"`
-	Price    int    `json:"price"`
+	ID        string     `json:"id" xml:"id"`
+	Make      string     `json:"make" xml:"make" validate:"required,max=64"`
+	Model     string     `json:"model" xml:"model" validate:"required,max=64"`
+	Package   string     `json:"package" xml:"package" validate:"max=64"`
+	Color     string     `json:"color" xml:"color" validate:"max=32"`
+	Category  string     `json:"category" xml:"category" validate:"max=32"`
+	Year      int        `json:"year" xml:"year" validate:"min=1886,maxyear"`
+	Mileage   int        `json:"mileage" xml:"mileage" validate:"min=0"`
+	Price     int        `json:"price" xml:"price" validate:"min=0"`
+	Version   int64      `json:"version" xml:"version"`
+	UpdatedBy string     `json:"updated_by,omitempty" xml:"updated_by,omitempty" validate:"max=64"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
+}
+
+// Deleted reports whether the car has been soft deleted.
+func (c *Car) Deleted() bool {
+	return c.DeletedAt != nil
+}
+
+// Clone returns a copy of the car that shares no memory with it.
+func (c *Car) Clone() *Car {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	if c.DeletedAt != nil {
+		deletedAt := *c.DeletedAt
+		clone.DeletedAt = &deletedAt
+	}
+	return &clone
 }
@@ -0,0 +1,35 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	t.Run("Copies every field", func(t *testing.T) {
+		deletedAt := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2019, Version: 3, DeletedAt: &deletedAt}
+
+		assert.Equal(t, car, car.Clone())
+	})
+
+	t.Run("Shares no memory", func(t *testing.T) {
+		deletedAt := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+		car := &model.Car{ID: "civic", Make: "Honda", DeletedAt: &deletedAt}
+
+		clone := car.Clone()
+		clone.Make = "Acura"
+		*clone.DeletedAt = deletedAt.Add(time.Hour)
+
+		assert.Equal(t, "Honda", car.Make)
+		assert.Equal(t, time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC), *car.DeletedAt)
+	})
+
+	t.Run("Nil car", func(t *testing.T) {
+		var car *model.Car
+		assert.Nil(t, car.Clone())
+	})
+}
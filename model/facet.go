@@ -0,0 +1,216 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Facet fields. Terms facets count the cars per value of a text field, range
+// facets count them per bucket of a number field.
+var (
+	TermsFacetFields = []string{"make", "color", "category"}
+	RangeFacetFields = []string{"year", "price", "mileage"}
+)
+
+// DefaultFacetSize is how many values a terms facet lists unless told otherwise.
+const DefaultFacetSize = 10
+
+// DefaultFacetBounds are the bucket bounds of range facets that are not given
+// any. Prices are in cents.
+var DefaultFacetBounds = map[string][]int{
+	"year":    {2000, 2005, 2010, 2015, 2020},
+	"price":   {1000000, 2000000, 3000000, 5000000},
+	"mileage": {10000, 50000, 100000},
+}
+
+// FacetSpec asks for one facet. Size caps the values of a terms facet, the most
+// common first, and 0 lists them all. Bounds split a range facet into buckets:
+// below the first bound, from each bound up to the next, and from the last up.
+type FacetSpec struct {
+	Field  string
+	Size   int
+	Bounds []int
+}
+
+// IsTermsFacet reports whether field is one of TermsFacetFields.
+func IsTermsFacet(field string) bool {
+	return contains(TermsFacetFields, field)
+}
+
+// IsRangeFacet reports whether field is one of RangeFacetFields.
+func IsRangeFacet(field string) bool {
+	return contains(RangeFacetFields, field)
+}
+
+// Validate checks that the spec names a facet field and, for range facets, has
+// strictly ascending bounds.
+func (s FacetSpec) Validate() error {
+	switch {
+	case IsTermsFacet(s.Field):
+		if s.Size < 0 {
+			return fmt.Errorf("size of facet %s must not be negative", s.Field)
+		}
+	case IsRangeFacet(s.Field):
+		if len(s.Bounds) == 0 {
+			return fmt.Errorf("range facet %s needs bounds", s.Field)
+		}
+		for i := 1; i < len(s.Bounds); i++ {
+			if s.Bounds[i] <= s.Bounds[i-1] {
+				return fmt.Errorf("bounds of facet %s must be ascending", s.Field)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown facet field %q", s.Field)
+	}
+	return nil
+}
+
+// Facets are the facet counts over the cars matching a query.
+type Facets struct {
+	Total  int     `json:"total" xml:"total"`
+	Facets []Facet `json:"facets" xml:"facets>facet"`
+}
+
+// Facet holds the counts of one field: Terms for a terms facet, Ranges for a
+// range facet.
+type Facet struct {
+	Field  string        `json:"field" xml:"field"`
+	Terms  []TermCount   `json:"terms,omitempty" xml:"terms>term,omitempty"`
+	Ranges []RangeBucket `json:"ranges,omitempty" xml:"ranges>range,omitempty"`
+}
+
+// TermCount is how many cars have Value in the facet's field.
+type TermCount struct {
+	Value string `json:"value" xml:"value"`
+	Count int    `json:"count" xml:"count"`
+}
+
+// RangeBucket is how many cars have the facet's field from From up to but not
+// including To. A nil bound leaves that side open.
+type RangeBucket struct {
+	From  *int `json:"from,omitempty" xml:"from,omitempty"`
+	To    *int `json:"to,omitempty" xml:"to,omitempty"`
+	Count int  `json:"count" xml:"count"`
+}
+
+// NewRangeBuckets returns the empty buckets bounds split a range into.
+func NewRangeBuckets(bounds []int) []RangeBucket {
+	buckets := make([]RangeBucket, len(bounds)+1)
+	for i := range bounds {
+		bound := bounds[i]
+		buckets[i].To = &bound
+		buckets[i+1].From = &bound
+	}
+	return buckets
+}
+
+// Bucket returns the index of the bucket bounds put value in.
+func Bucket(bounds []int, value int) int {
+	return sort.Search(len(bounds), func(i int) bool { return value < bounds[i] })
+}
+
+// SortTerms orders terms the most common first, then by value, and keeps the
+// first size of them unless size is 0.
+func SortTerms(terms []TermCount, size int) []TermCount {
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Value < terms[j].Value
+	})
+	if size > 0 && len(terms) > size {
+		terms = terms[:size]
+	}
+	return terms
+}
+
+// FacetCounter computes facets in memory, one car at a time.
+type FacetCounter struct {
+	specs  []FacetSpec
+	total  int
+	terms  []map[string]int
+	ranges [][]RangeBucket
+}
+
+// NewFacetCounter counts the facets specs ask for. The specs must be valid.
+func NewFacetCounter(specs []FacetSpec) *FacetCounter {
+	counter := &FacetCounter{
+		specs:  specs,
+		terms:  make([]map[string]int, len(specs)),
+		ranges: make([][]RangeBucket, len(specs)),
+	}
+	for i, spec := range specs {
+		if IsTermsFacet(spec.Field) {
+			counter.terms[i] = make(map[string]int)
+		} else {
+			counter.ranges[i] = NewRangeBuckets(spec.Bounds)
+		}
+	}
+	return counter
+}
+
+// Add counts car. Empty text values are left out of terms facets.
+func (c *FacetCounter) Add(car *Car) {
+	c.total++
+	for i, spec := range c.specs {
+		if c.terms[i] != nil {
+			if value := textField(spec.Field, car); value != "" {
+				c.terms[i][value]++
+			}
+			continue
+		}
+		c.ranges[i][Bucket(spec.Bounds, numberField(spec.Field, car))].Count++
+	}
+}
+
+// Facets returns the counts so far, in the order of the specs.
+func (c *FacetCounter) Facets() *Facets {
+	facets := &Facets{Total: c.total, Facets: make([]Facet, len(c.specs))}
+	for i, spec := range c.specs {
+		facet := Facet{Field: spec.Field}
+		if c.terms[i] != nil {
+			facet.Terms = []TermCount{}
+			for value, count := range c.terms[i] {
+				facet.Terms = append(facet.Terms, TermCount{Value: value, Count: count})
+			}
+			facet.Terms = SortTerms(facet.Terms, spec.Size)
+		} else {
+			facet.Ranges = append([]RangeBucket(nil), c.ranges[i]...)
+		}
+		facets.Facets[i] = facet
+	}
+	return facets
+}
+
+func textField(field string, car *Car) string {
+	switch field {
+	case "make":
+		return car.Make
+	case "color":
+		return car.Color
+	case "category":
+		return car.Category
+	}
+	return ""
+}
+
+func numberField(field string, car *Car) int {
+	switch field {
+	case "year":
+		return car.Year
+	case "price":
+		return car.Price
+	case "mileage":
+		return car.Mileage
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
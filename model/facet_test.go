@@ -0,0 +1,52 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFacetSpecValidate(t *testing.T) {
+	assert.NoError(t, model.FacetSpec{Field: "make"}.Validate())
+	assert.NoError(t, model.FacetSpec{Field: "year", Bounds: []int{2000, 2010}}.Validate())
+
+	assert.Error(t, model.FacetSpec{Field: "model"}.Validate())
+	assert.Error(t, model.FacetSpec{Field: "make", Size: -1}.Validate())
+	assert.Error(t, model.FacetSpec{Field: "price"}.Validate())
+	assert.Error(t, model.FacetSpec{Field: "price", Bounds: []int{20, 10}}.Validate())
+}
+
+func TestFacetCounter(t *testing.T) {
+	counter := model.NewFacetCounter([]model.FacetSpec{
+		{Field: "make", Size: 2},
+		{Field: "color"},
+		{Field: "year", Bounds: []int{2010, 2020}},
+	})
+	for _, car := range []*model.Car{
+		{Make: "Toyota", Color: "Red", Year: 2005},
+		{Make: "Toyota", Color: "", Year: 2010},
+		{Make: "Honda", Color: "Blue", Year: 2019},
+		{Make: "Kia", Color: "Red", Year: 2020},
+		{Make: "Honda", Color: "Red", Year: 2024},
+	} {
+		counter.Add(car)
+	}
+
+	facets := counter.Facets()
+	assert.Equal(t, 5, facets.Total)
+
+	// the most common first, ties by value, cut to size
+	assert.Equal(t, []model.TermCount{{Value: "Honda", Count: 2}, {Value: "Toyota", Count: 2}}, facets.Facets[0].Terms)
+
+	// empty values are not counted
+	assert.Equal(t, []model.TermCount{{Value: "Red", Count: 3}, {Value: "Blue", Count: 1}}, facets.Facets[1].Terms)
+
+	// buckets include their lower bound and exclude the upper one
+	ranges := facets.Facets[2].Ranges
+	assert.Equal(t, []model.RangeBucket{
+		{To: intPtr(2010), Count: 1},
+		{From: intPtr(2010), To: intPtr(2020), Count: 2},
+		{From: intPtr(2020), Count: 2},
+	}, ranges)
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DalvinCodes/cars/utils"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// PageRequest asks for up to Limit cars after (or before) the position encoded
+// in Cursor. An empty cursor starts at the first car.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// Page is one slice of a sorted result set, with cursors to its neighbours.
+// A cursor is empty when there is no page in that direction.
+type Page struct {
+	Cars       []*Car
+	Total      int
+	NextCursor string
+	PrevCursor string
+}
+
+// cursor marks a position in a result set by the sort key of the car on the
+// edge of a page, so it stays valid while cars are added or removed.
+type cursor struct {
+	Sort   string `json:"s"`
+	Before bool   `json:"b,omitempty"`
+	Key    Car    `json:"k"`
+}
+
+// Paginate cuts a page out of cars, which must already be ordered by query.
+func Paginate(cars []*Car, query Query, req PageRequest) (*Page, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	start, end := 0, len(cars)
+	before := false
+	if req.Cursor != "" {
+		c, err := decodeCursor(req.Cursor, query)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Before {
+			// the page ends just before the first car that does not sort ahead of the key
+			before = true
+			end = sort.Search(len(cars), func(i int) bool {
+				return query.Compare(cars[i], &c.Key) >= 0
+			})
+		} else {
+			// the page starts at the first car that sorts after the key
+			start = sort.Search(len(cars), func(i int) bool {
+				return query.Compare(cars[i], &c.Key) > 0
+			})
+		}
+	}
+
+	if before {
+		if end-limit > start {
+			start = end - limit
+		}
+	} else if start+limit < end {
+		end = start + limit
+	}
+
+	page := &Page{
+		Cars:  make([]*Car, 0, end-start),
+		Total: len(cars),
+	}
+	page.Cars = append(page.Cars, cars[start:end]...)
+
+	if end < len(cars) && end > start {
+		page.NextCursor = encodeCursor(cursor{Sort: sortKey(query), Key: cursorKey(cars[end-1], query)})
+	}
+	if start > 0 && end > start {
+		page.PrevCursor = encodeCursor(cursor{Sort: sortKey(query), Before: true, Key: cursorKey(cars[start], query)})
+	}
+	return page, nil
+}
+
+// cursorKey copies the fields of car that query orders by.
+func cursorKey(car *Car, query Query) Car {
+	key := Car{ID: car.ID}
+	for _, field := range query.Sort {
+		switch field.Field {
+		case "make":
+			key.Make = car.Make
+		case "model":
+			key.Model = car.Model
+		case "package":
+			key.Package = car.Package
+		case "color":
+			key.Color = car.Color
+		case "category":
+			key.Category = car.Category
+		case "year":
+			key.Year = car.Year
+		case "mileage":
+			key.Mileage = car.Mileage
+		case "price":
+			key.Price = car.Price
+		}
+	}
+	return key
+}
+
+func sortKey(query Query) string {
+	var parts []string
+	for _, field := range query.Sort {
+		if field.Desc {
+			parts = append(parts, "-"+field.Field)
+		} else {
+			parts = append(parts, field.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeRaw(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(raw, &c)
+	return c, err
+}
+
+func decodeCursor(s string, query Query) (cursor, error) {
+	c, err := decodeRaw(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", utils.ErrBadQuery)
+	}
+	// a cursor only makes sense for the order it was created with
+	if c.Sort != sortKey(query) {
+		return c, fmt.Errorf("%w: cursor does not match sort order", utils.ErrBadQuery)
+	}
+	return c, nil
+}
@@ -0,0 +1,70 @@
+package model_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func pageIDs(page *model.Page) []string {
+	ids := []string{}
+	for _, car := range page.Cars {
+		ids = append(ids, car.ID)
+	}
+	return ids
+}
+
+func TestPaginate(t *testing.T) {
+	var cars []*model.Car
+	for i := 0; i < 5; i++ {
+		cars = append(cars, &model.Car{ID: fmt.Sprintf("car-%d", i), Price: 100 * (i % 2)})
+	}
+	query := model.Query{Sort: []model.SortField{{Field: "price"}}}
+	query.SortCars(cars)
+
+	t.Run("Walks forwards and backwards", func(t *testing.T) {
+		first, err := model.Paginate(cars, query, model.PageRequest{Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"car-0", "car-2"}, pageIDs(first))
+		assert.Equal(t, 5, first.Total)
+		assert.Empty(t, first.PrevCursor)
+
+		second, err := model.Paginate(cars, query, model.PageRequest{Limit: 2, Cursor: first.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"car-4", "car-1"}, pageIDs(second))
+
+		last, err := model.Paginate(cars, query, model.PageRequest{Limit: 2, Cursor: second.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"car-3"}, pageIDs(last))
+		assert.Empty(t, last.NextCursor)
+
+		back, err := model.Paginate(cars, query, model.PageRequest{Limit: 2, Cursor: last.PrevCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, pageIDs(second), pageIDs(back))
+	})
+
+	t.Run("Cursor survives removal of its car", func(t *testing.T) {
+		first, err := model.Paginate(cars, query, model.PageRequest{Limit: 2})
+		assert.NoError(t, err)
+
+		// drop car-2, the last car of the first page
+		remaining := append([]*model.Car{cars[0]}, cars[2:]...)
+		second, err := model.Paginate(remaining, query, model.PageRequest{Limit: 2, Cursor: first.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"car-4", "car-1"}, pageIDs(second))
+	})
+
+	t.Run("Rejects foreign cursors", func(t *testing.T) {
+		first, err := model.Paginate(cars, query, model.PageRequest{Limit: 2})
+		assert.NoError(t, err)
+
+		_, err = model.Paginate(cars, model.Query{}, model.PageRequest{Cursor: first.NextCursor})
+		assert.ErrorIs(t, err, utils.ErrBadQuery)
+
+		_, err = model.Paginate(cars, query, model.PageRequest{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, utils.ErrBadQuery)
+	})
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Query selects and orders cars. String filters match case-insensitively and
+// nil bounds are ignored. Soft deleted cars only match when IncludeDeleted is set.
+type Query struct {
+	Make     string
+	Model    string
+	Color    string
+	Category string
+
+	YearMin    *int
+	YearMax    *int
+	PriceMin   *int
+	PriceMax   *int
+	MileageMax *int
+
+	IncludeDeleted bool
+
+	Sort []SortField
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// SortableFields lists the fields a Query can be ordered by.
+var SortableFields = []string{"id", "make", "model", "package", "color", "category", "year", "mileage", "price"}
+
+// ParseSort reads a comma separated list of fields, each optionally prefixed
+// with "-" for descending order, e.g. "price,-year".
+func ParseSort(s string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !IsSortable(field.Field) {
+			return nil, fmt.Errorf("unknown sort field %q", field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// IsSortable reports whether field is one of SortableFields.
+func IsSortable(field string) bool {
+	for _, f := range SortableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether car passes every filter in the query.
+func (q Query) Matches(car *Car) bool {
+	if !q.IncludeDeleted && car.Deleted() {
+		return false
+	}
+	if q.Make != "" && !strings.EqualFold(car.Make, q.Make) {
+		return false
+	}
+	if q.Model != "" && !strings.EqualFold(car.Model, q.Model) {
+		return false
+	}
+	if q.Color != "" && !strings.EqualFold(car.Color, q.Color) {
+		return false
+	}
+	if q.Category != "" && !strings.EqualFold(car.Category, q.Category) {
+		return false
+	}
+	if q.YearMin != nil && car.Year < *q.YearMin {
+		return false
+	}
+	if q.YearMax != nil && car.Year > *q.YearMax {
+		return false
+	}
+	if q.PriceMin != nil && car.Price < *q.PriceMin {
+		return false
+	}
+	if q.PriceMax != nil && car.Price > *q.PriceMax {
+		return false
+	}
+	if q.MileageMax != nil && car.Mileage > *q.MileageMax {
+		return false
+	}
+	return true
+}
+
+// Compare orders a before b by the query's sort fields, breaking ties by ID so
+// the order is stable. It returns a negative number, zero or a positive number.
+func (q Query) Compare(a, b *Car) int {
+	for _, field := range q.Sort {
+		c := compareField(field.Field, a, b)
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// SortCars orders cars in place by the query's sort fields.
+func (q Query) SortCars(cars []*Car) {
+	sort.SliceStable(cars, func(i, j int) bool {
+		return q.Compare(cars[i], cars[j]) < 0
+	})
+}
+
+func compareField(field string, a, b *Car) int {
+	switch field {
+	case "id":
+		return strings.Compare(a.ID, b.ID)
+	case "make":
+		return strings.Compare(a.Make, b.Make)
+	case "model":
+		return strings.Compare(a.Model, b.Model)
+	case "package":
+		return strings.Compare(a.Package, b.Package)
+	case "color":
+		return strings.Compare(a.Color, b.Color)
+	case "category":
+		return strings.Compare(a.Category, b.Category)
+	case "year":
+		return compareInt(a.Year, b.Year)
+	case "mileage":
+		return compareInt(a.Mileage, b.Mileage)
+	case "price":
+		return compareInt(a.Price, b.Price)
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestParseSort(t *testing.T) {
+	t.Run("Parses ascending and descending fields", func(t *testing.T) {
+		fields, err := model.ParseSort("price, -year")
+		assert.NoError(t, err)
+		assert.Equal(t, []model.SortField{{Field: "price"}, {Field: "year", Desc: true}}, fields)
+	})
+
+	t.Run("Rejects unknown fields", func(t *testing.T) {
+		_, err := model.ParseSort("price,-horsepower")
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryMatches(t *testing.T) {
+	car := &model.Car{Make: "Honda", Model: "Civic", Color: "Red", Category: "Sedan", Year: 2019, Mileage: 12000, Price: 2000000}
+
+	tests := []struct {
+		name  string
+		query model.Query
+		want  bool
+	}{
+		{"Empty query", model.Query{}, true},
+		{"Make ignores case", model.Query{Make: "honda"}, true},
+		{"Different model", model.Query{Model: "Accord"}, false},
+		{"Year in range", model.Query{YearMin: intPtr(2018), YearMax: intPtr(2019)}, true},
+		{"Year too old", model.Query{YearMin: intPtr(2020)}, false},
+		{"Price too high", model.Query{PriceMax: intPtr(1999999)}, false},
+		{"Mileage bound of zero", model.Query{MileageMax: intPtr(0)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.query.Matches(car))
+		})
+	}
+}
+
+func TestQuerySortCars(t *testing.T) {
+	cars := []*model.Car{
+		{ID: "a", Price: 300, Year: 2020},
+		{ID: "b", Price: 100, Year: 2019},
+		{ID: "c", Price: 100, Year: 2021},
+		{ID: "d", Price: 100, Year: 2021},
+	}
+
+	sort, err := model.ParseSort("price,-year")
+	assert.NoError(t, err)
+
+	model.Query{Sort: sort}.SortCars(cars)
+
+	var ids []string
+	for _, car := range cars {
+		ids = append(ids, car.ID)
+	}
+	assert.Equal(t, []string{"c", "d", "b", "a"}, ids)
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionPurge   = "purge"
+)
+
+// Revision is one recorded write to a car. Numbers count the writes to a car
+// from 1 and, unlike versions, keep growing when a purged car is saved again.
+// Car is the state after the write and is nil for purges.
+type Revision struct {
+	Number int       `json:"number" xml:"number"`
+	Op     string    `json:"op" xml:"op"`
+	Actor  string    `json:"actor,omitempty" xml:"actor,omitempty"`
+	At     time.Time `json:"at" xml:"at"`
+	Car    *Car      `json:"car,omitempty" xml:"car,omitempty"`
+}
+
+// Change is the difference in one field between two states of a car. From or
+// To is nil when the car did not exist on that side.
+type Change struct {
+	Field string `json:"field" xml:"field"`
+	From  any    `json:"from" xml:"from,omitempty"`
+	To    any    `json:"to" xml:"to,omitempty"`
+}
+
+// Diff lists the fields that differ between from and to by their JSON names,
+// in alphabetical order. Either car may be nil.
+func Diff(from, to *Car) []Change {
+	before, after := fields(from), fields(to)
+
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	changes := []Change{}
+	for name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, Change{Field: name, From: before[name], To: after[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// fields flattens a car into its JSON members.
+func fields(car *Car) map[string]any {
+	if car == nil {
+		return nil
+	}
+	raw, _ := json.Marshal(car)
+	var members map[string]any
+	_ = json.Unmarshal(raw, &members)
+	return members
+}
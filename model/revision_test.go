@@ -0,0 +1,31 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Run("Lists changed fields", func(t *testing.T) {
+		from := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Price: 2000000, Version: 1}
+		to := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Price: 1900000, Version: 2, UpdatedBy: "bob"}
+
+		assert.Equal(t, []model.Change{
+			{Field: "price", From: float64(2000000), To: float64(1900000)},
+			{Field: "updated_by", From: nil, To: "bob"},
+			{Field: "version", From: float64(1), To: float64(2)},
+		}, model.Diff(from, to))
+	})
+
+	t.Run("Identical cars have no changes", func(t *testing.T) {
+		car := &model.Car{ID: "civic", Make: "Honda"}
+		assert.Empty(t, model.Diff(car, car.Clone()))
+	})
+
+	t.Run("Missing car", func(t *testing.T) {
+		changes := model.Diff(&model.Car{ID: "civic", Make: "Honda"}, nil)
+		assert.Contains(t, changes, model.Change{Field: "make", From: "Honda", To: nil})
+	})
+}
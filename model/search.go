@@ -0,0 +1,13 @@
+package model
+
+// Limits on the number of hits a search returns.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchHit is a car found by a full-text search, with how relevant it is.
+type SearchHit struct {
+	Car   *Car    `json:"car" xml:"car"`
+	Score float64 `json:"score" xml:"score"`
+}
@@ -0,0 +1,110 @@
+package patch_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/patch"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"Replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"Add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"Null removes member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"Nested objects merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":"g"}}`, `{"a":{"b":"c","f":"g"}}`},
+		{"Arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"Non object replaces document", `{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patch.MergePatch(tt.patch).Apply([]byte(tt.doc))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := patch.MergePatch(`{`).Apply([]byte(`{}`))
+		assert.ErrorIs(t, err, utils.ErrBadBody)
+	})
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"Add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{"Add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"Append to array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"Remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"Remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"Replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"Move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"Copy value", `{"a":1}`, `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":1,"b":1}`},
+		{"Test passes", `{"price":100}`, `[{"op":"test","path":"/price","value":100},{"op":"replace","path":"/price","value":90}]`, `{"price":90}`},
+		{"Escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := patch.ParseJSONPatch([]byte(tt.patch))
+			assert.NoError(t, err)
+
+			got, err := p.Apply([]byte(tt.doc))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+
+	failures := []struct {
+		name  string
+		patch string
+		err   error
+	}{
+		{"Failed test", `[{"op":"test","path":"/price","value":1}]`, utils.ErrConflict},
+		{"Replace missing member", `[{"op":"replace","path":"/color","value":"red"}]`, utils.ErrUnprocessable},
+		{"Remove missing member", `[{"op":"remove","path":"/color"}]`, utils.ErrUnprocessable},
+		{"Index out of range", `[{"op":"add","path":"/tags/5","value":"x"}]`, utils.ErrUnprocessable},
+	}
+
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := patch.ParseJSONPatch([]byte(tt.patch))
+			assert.NoError(t, err)
+
+			_, err = p.Apply([]byte(`{"price":100,"tags":[]}`))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseJSONPatch(t *testing.T) {
+	invalid := []struct {
+		name  string
+		patch string
+	}{
+		{"Not an array", `{"op":"add"}`},
+		{"Unknown op", `[{"op":"merge","path":"/a"}]`},
+		{"Missing value", `[{"op":"add","path":"/a"}]`},
+		{"Bad pointer", `[{"op":"remove","path":"a"}]`},
+		{"Bad from", `[{"op":"move","from":"a","path":"/b"}]`},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := patch.ParseJSONPatch([]byte(tt.patch))
+			assert.ErrorIs(t, err, utils.ErrBadBody)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package repository_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+)
+
+const benchCars = 10000
+
+// benchStores are the in-memory stores compared by the benchmarks.
+var benchStores = []struct {
+	name    string
+	storage func() repository.Storage
+}{
+	{"Repo", func() repository.Storage { return repository.NewRepo() }},
+	{"ShardedRepo", func() repository.Storage { return repository.NewShardedRepo(repository.DefaultShards) }},
+}
+
+func newBenchStore(b *testing.B, newStorage func() repository.Storage) repository.Storage {
+	b.Helper()
+
+	store := newStorage()
+	for _, car := range fleet(benchCars) {
+		if err := store.Save(car.ID, car); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return store
+}
+
+// BenchmarkMixedLoad runs Gets and Updates on random cars from parallel
+// goroutines, at several shares of writes.
+func BenchmarkMixedLoad(b *testing.B) {
+	for _, writes := range []int{10, 50, 90} {
+		for _, bench := range benchStores {
+			b.Run(fmt.Sprintf("%s/writes=%d%%", bench.name, writes), func(b *testing.B) {
+				store := newBenchStore(b, bench.storage)
+				var seed int64
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rnd := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+					car := &model.Car{Make: "Honda", Model: "Civic", Year: 2020}
+					for pb.Next() {
+						key := fmt.Sprintf("car-%03d", rnd.Intn(benchCars))
+						if rnd.Intn(100) < writes {
+							car.ID, car.Version, car.Price = key, 0, rnd.Intn(5000000)
+							if err := store.Update(key, car); err != nil {
+								b.Error(err)
+								return
+							}
+						} else if _, err := store.Get(key); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkFind runs filtered queries while other goroutines keep writing.
+func BenchmarkFind(b *testing.B) {
+	for _, bench := range benchStores {
+		b.Run(bench.name, func(b *testing.B) {
+			store := newBenchStore(b, bench.storage)
+			var seed int64
+			yearMin := 2023
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rnd := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+				for pb.Next() {
+					if rnd.Intn(2) == 0 {
+						key := fmt.Sprintf("car-%03d", rnd.Intn(benchCars))
+						if err := store.Save(key, &model.Car{ID: key, Make: "Ford", Year: 2000 + rnd.Intn(25)}); err != nil {
+							b.Error(err)
+							return
+						}
+					} else if _, err := store.Find(model.Query{Make: "Tesla", YearMin: &yearMin}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+)
+
+// CacheOptions tunes a CacheStore. The zero value caches every result until a
+// write drops it.
+type CacheOptions struct {
+	// TTL is how long a result is served from the cache. Zero keeps results
+	// until they are evicted or invalidated.
+	TTL time.Duration
+	// MaxEntries bounds how many results the cache holds, dropping the least
+	// recently used first. Zero leaves it unbounded.
+	MaxEntries int
+	// Now tells the time for the TTL. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// CacheStats counts how a CacheStore answered reads.
+type CacheStats struct {
+	// Hits were answered from the cache.
+	Hits int64
+	// Misses went to the underlying storage.
+	Misses int64
+	// Shared are the misses that waited for a load of the same result that
+	// was already running instead of starting their own.
+	Shared int64
+	// Evictions are the results dropped to stay within MaxEntries.
+	Evictions int64
+	// Entries is the number of results held now.
+	Entries int
+}
+
+// CacheStore is a Storage that keeps the results of Get, GetAll and Find in
+// memory and reads through to the underlying storage on a miss. Concurrent
+// misses for the same result share a single load. Writes through the store,
+// including committed transactions, drop the results they affect, which for
+// GetAll and Find is every result; writes that bypass it are only seen once
+// the TTL runs out. Iterate streams from the underlying storage and is not
+// cached.
+type CacheStore struct {
+	Storage
+	opts CacheOptions
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List // of *cacheEntry, most recently used first
+	finds   map[cacheKey]bool
+	loads   map[cacheKey]*cacheLoad
+	gen     uint64 // bumped by every invalidation
+	stats   CacheStats
+}
+
+// cacheKey names a cached result: the car under key, all cars, or the cars
+// found by the query key encodes.
+type cacheKey struct {
+	all  bool
+	find bool
+	key  string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	cars    []*model.Car // a single car for Get
+	expires time.Time    // zero when it never expires
+}
+
+// cacheLoad is a read of the underlying storage that misses of the same result
+// wait on.
+type cacheLoad struct {
+	done chan struct{}
+	cars []*model.Car
+	err  error
+}
+
+// NewCacheStore caches the reads of storage.
+func NewCacheStore(storage Storage, opts CacheOptions) *CacheStore {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &CacheStore{
+		Storage: storage,
+		opts:    opts,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+		finds:   make(map[cacheKey]bool),
+		loads:   make(map[cacheKey]*cacheLoad),
+	}
+}
+
+// Unwrap returns the underlying storage.
+func (s *CacheStore) Unwrap() Storage {
+	return s.Storage
+}
+
+// Stats returns the counters so far.
+func (s *CacheStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Entries = s.lru.Len()
+	return stats
+}
+
+func (s *CacheStore) Get(key string) (*model.Car, error) {
+	cars, err := s.load(cacheKey{key: key}, func() ([]*model.Car, error) {
+		car, err := s.Storage.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.Car{car}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cars[0], nil
+}
+
+func (s *CacheStore) GetAll() ([]*model.Car, error) {
+	return s.load(cacheKey{all: true}, s.Storage.GetAll)
+}
+
+// Find caches the cars found under the query, encoded as JSON, so equal
+// queries share a result.
+func (s *CacheStore) Find(query model.Query) ([]*model.Car, error) {
+	encoded, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(cacheKey{find: true, key: string(encoded)}, func() ([]*model.Car, error) {
+		return s.Storage.Find(query)
+	})
+}
+
+func (s *CacheStore) Save(key string, object *model.Car) error {
+	err := s.Storage.Save(key, object)
+	s.invalidate(key)
+	return err
+}
+
+func (s *CacheStore) Update(key string, object *model.Car) error {
+	err := s.Storage.Update(key, object)
+	s.invalidate(key)
+	return err
+}
+
+func (s *CacheStore) Delete(key string) error {
+	err := s.Storage.Delete(key)
+	s.invalidate(key)
+	return err
+}
+
+// Begin starts a transaction on the underlying storage. Its reads bypass the
+// cache, and its writes invalidate the cache once it commits.
+func (s *CacheStore) Begin() (Tx, error) {
+	tx, err := s.Storage.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return watchTx(tx, func(writes []txWrite) {
+		s.invalidate(writtenKeys(writes)...)
+	}), nil
+}
+
+// load returns copies of the result cached under key, fetching it on a miss.
+func (s *CacheStore) load(key cacheKey, fetch func() ([]*model.Car, error)) ([]*model.Car, error) {
+	s.mu.Lock()
+	if cars, ok := s.lookup(key); ok {
+		s.stats.Hits++
+		s.mu.Unlock()
+		return cloneCars(cars), nil
+	}
+	s.stats.Misses++
+	if running, ok := s.loads[key]; ok {
+		s.stats.Shared++
+		s.mu.Unlock()
+		<-running.done
+		return cloneCars(running.cars), running.err
+	}
+	current := &cacheLoad{done: make(chan struct{})}
+	s.loads[key] = current
+	gen := s.gen
+	s.mu.Unlock()
+
+	current.cars, current.err = fetch()
+
+	s.mu.Lock()
+	if s.loads[key] == current {
+		delete(s.loads, key)
+	}
+	// a write that landed during the fetch may not be in what was fetched
+	if current.err == nil && gen == s.gen {
+		s.insert(key, current.cars)
+	}
+	s.mu.Unlock()
+	close(current.done)
+
+	return cloneCars(current.cars), current.err
+}
+
+// lookup returns the live result under key and marks it as recently used. The
+// caller holds s.mu.
+func (s *CacheStore) lookup(key cacheKey) ([]*model.Car, bool) {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && !s.opts.Now().Before(entry.expires) {
+		s.drop(key)
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+	return entry.cars, true
+}
+
+// insert caches cars under key, evicting the least recently used results past
+// MaxEntries. The caller holds s.mu.
+func (s *CacheStore) insert(key cacheKey, cars []*model.Car) {
+	entry := &cacheEntry{key: key, cars: cars}
+	if s.opts.TTL > 0 {
+		entry.expires = s.opts.Now().Add(s.opts.TTL)
+	}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.lru.MoveToFront(element)
+	} else {
+		s.entries[key] = s.lru.PushFront(entry)
+	}
+	if key.find {
+		s.finds[key] = true
+	}
+
+	for s.opts.MaxEntries > 0 && s.lru.Len() > s.opts.MaxEntries {
+		oldest := s.lru.Back().Value.(*cacheEntry)
+		s.drop(oldest.key)
+		s.stats.Evictions++
+	}
+}
+
+// drop forgets the result under key. The caller holds s.mu.
+func (s *CacheStore) drop(key cacheKey) {
+	if element, ok := s.entries[key]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, key)
+		delete(s.finds, key)
+	}
+}
+
+// invalidate drops the cars under keys, the list of all cars and every Find
+// result, and keeps loads running since then out of the cache. Reads that come
+// after wait for none of those loads and fetch afresh.
+func (s *CacheStore) invalidate(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	all := cacheKey{all: true}
+	s.drop(all)
+	delete(s.loads, all)
+	for key := range s.finds {
+		s.drop(key)
+	}
+	for key := range s.loads {
+		if key.find {
+			delete(s.loads, key)
+		}
+	}
+	for _, key := range keys {
+		s.drop(cacheKey{key: key})
+		delete(s.loads, cacheKey{key: key})
+	}
+}
+
+func cloneCars(cars []*model.Car) []*model.Car {
+	if cars == nil {
+		return nil
+	}
+	clones := make([]*model.Car, len(cars))
+	for i, car := range cars {
+		clones[i] = car.Clone()
+	}
+	return clones
+}
@@ -0,0 +1,250 @@
+package repository_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStorage counts the reads that reach it and, when gate is set, holds
+// the car every Get read until the gate is closed.
+type countingStorage struct {
+	repository.Storage
+	gets    int32
+	getAlls int32
+	finds   int32
+	gate    chan struct{}
+}
+
+func (s *countingStorage) Get(key string) (*model.Car, error) {
+	car, err := s.Storage.Get(key)
+	atomic.AddInt32(&s.gets, 1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return car, err
+}
+
+func (s *countingStorage) GetAll() ([]*model.Car, error) {
+	atomic.AddInt32(&s.getAlls, 1)
+	return s.Storage.GetAll()
+}
+
+func (s *countingStorage) Find(query model.Query) ([]*model.Car, error) {
+	atomic.AddInt32(&s.finds, 1)
+	return s.Storage.Find(query)
+}
+
+func TestCacheStore(t *testing.T) {
+	t.Run("Serves repeated reads from the cache", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		for i := 0; i < 3; i++ {
+			object, err := store.Get("civic")
+			assert.NoError(t, err)
+			assert.Equal(t, "Honda", object.Make)
+
+			cars, err := store.GetAll()
+			assert.NoError(t, err)
+			assert.Len(t, cars, 1)
+		}
+
+		assert.Equal(t, int32(1), backend.gets)
+		assert.Equal(t, int32(1), backend.getAlls)
+		assert.Equal(t, repository.CacheStats{Hits: 4, Misses: 2, Entries: 2}, store.Stats())
+	})
+
+	t.Run("Caches Find by query", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda", Year: 2019}))
+		assert.NoError(t, store.Save("camry", &model.Car{ID: "camry", Make: "Toyota", Year: 2020}))
+
+		yearMin := 2020
+		for i := 0; i < 3; i++ {
+			cars, err := store.Find(model.Query{})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"camry", "civic"}, carIDs(cars))
+
+			// an equal query built anew shares the result
+			from := yearMin
+			cars, err = store.Find(model.Query{YearMin: &from})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"camry"}, carIDs(cars))
+		}
+		assert.Equal(t, int32(2), backend.finds)
+
+		// any write may change what a query finds
+		assert.NoError(t, store.Save("fit", &model.Car{ID: "fit", Make: "Honda", Year: 2021}))
+		cars, err := store.Find(model.Query{YearMin: &yearMin})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"camry", "fit"}, carIDs(cars))
+		assert.Equal(t, int32(3), backend.finds)
+	})
+
+	t.Run("Hands out copies", func(t *testing.T) {
+		store := repository.NewCacheStore(repository.NewRepo(), repository.CacheOptions{})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		object, err := store.Get("civic")
+		assert.NoError(t, err)
+		object.Make = "Toyota"
+
+		object, err = store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Honda", object.Make)
+	})
+
+	t.Run("Misses are not cached", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{})
+
+		_, err := store.Get("civic")
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+		assert.NoError(t, backend.Storage.Save("civic", &model.Car{ID: "civic"}))
+
+		_, err = store.Get("civic")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Results expire after the TTL", func(t *testing.T) {
+		now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{
+			TTL: time.Minute,
+			Now: func() time.Time { return now },
+		})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+		_, err := store.Get("civic")
+		assert.NoError(t, err)
+
+		// a write behind the cache's back shows once the TTL runs out
+		assert.NoError(t, backend.Storage.Update("civic", &model.Car{ID: "civic", Make: "Toyota"}))
+
+		now = now.Add(59 * time.Second)
+		object, err := store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Honda", object.Make)
+
+		now = now.Add(time.Second)
+		object, err = store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Toyota", object.Make)
+		assert.Equal(t, int32(2), backend.gets)
+	})
+
+	t.Run("Evicts the least recently used results", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{MaxEntries: 2})
+		for _, key := range []string{"civic", "camry", "fit"} {
+			assert.NoError(t, store.Save(key, &model.Car{ID: key}))
+		}
+
+		for _, key := range []string{"civic", "camry", "civic", "fit"} {
+			_, err := store.Get(key)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(3), backend.gets)
+
+		// camry was used least recently, so it went to make room for fit
+		_, err := store.Get("civic")
+		assert.NoError(t, err)
+		_, err = store.Get("camry")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(4), backend.gets)
+		assert.Equal(t, repository.CacheStats{Hits: 2, Misses: 4, Evictions: 2, Entries: 2}, store.Stats())
+	})
+
+	t.Run("Writes invalidate what they affect", func(t *testing.T) {
+		store := repository.NewCacheStore(repository.NewRepo(), repository.CacheOptions{})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+		assert.NoError(t, store.Save("camry", &model.Car{ID: "camry", Make: "Toyota"}))
+		_, err := store.Get("civic")
+		assert.NoError(t, err)
+		_, err = store.GetAll()
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Make: "Acura"}))
+		object, err := store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Acura", object.Make)
+
+		assert.NoError(t, store.Delete("camry"))
+		cars, err := store.GetAll()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, carIDs(cars))
+	})
+
+	t.Run("Committed transactions invalidate what they wrote", func(t *testing.T) {
+		store := repository.NewCacheStore(repository.NewRepo(), repository.CacheOptions{})
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+		_, err := store.Get("civic")
+		assert.NoError(t, err)
+
+		err = repository.WithTx(store, func(tx repository.Tx) error {
+			return tx.Update("civic", &model.Car{ID: "civic", Make: "Acura"})
+		})
+		assert.NoError(t, err)
+
+		object, err := store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Acura", object.Make)
+	})
+
+	t.Run("Concurrent misses share one load", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo(), gate: make(chan struct{})}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{})
+		assert.NoError(t, backend.Storage.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				object, err := store.Get("civic")
+				assert.NoError(t, err)
+				assert.Equal(t, "Honda", object.Make)
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			return store.Stats().Misses == 10
+		}, time.Second, time.Millisecond)
+		close(backend.gate)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), backend.gets)
+		assert.Equal(t, int64(9), store.Stats().Shared)
+	})
+
+	t.Run("A write during a load keeps its result out", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo(), gate: make(chan struct{})}
+		store := repository.NewCacheStore(backend, repository.CacheOptions{})
+		assert.NoError(t, backend.Storage.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		loaded := make(chan *model.Car)
+		go func() {
+			object, _ := store.Get("civic")
+			loaded <- object
+		}()
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&backend.gets) == 1
+		}, time.Second, time.Millisecond)
+
+		// the load read the old car before the write got in
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Make: "Acura"}))
+		close(backend.gate)
+		assert.Equal(t, "Honda", (<-loaded).Make)
+
+		object, err := store.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Acura", object.Make)
+	})
+}
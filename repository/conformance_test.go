@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/repository/storagetest"
+)
+
+func TestRepoConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		return repository.NewRepo()
+	})
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		store := newFileStore(t, t.TempDir())
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestSQLStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		return newSQLStore(t)
+	})
+}
+
+func TestHistoryStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		return repository.NewHistoryStore(repository.NewRepo(), time.Now)
+	})
+}
+
+func TestSearchStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		store, err := repository.NewSearchStore(repository.NewRepo())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestShardedRepoConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		return repository.NewShardedRepo(4)
+	})
+}
+
+func TestCacheStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() repository.Storage {
+		return repository.NewCacheStore(repository.NewRepo(), repository.CacheOptions{MaxEntries: 4})
+	})
+}
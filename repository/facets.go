@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/DalvinCodes/cars/model"
+)
+
+// Aggregator is implemented by storage that computes facets itself rather than
+// having every matching car counted in memory.
+type Aggregator interface {
+	// Facets counts the cars matching query by the facets specs ask for. The
+	// specs must be valid.
+	Facets(query model.Query, specs []model.FacetSpec) (*model.Facets, error)
+}
+
+// Facets groups and counts in the database.
+func (s *sqlCars) Facets(query model.Query, specs []model.FacetSpec) (*model.Facets, error) {
+	where, args := whereClause(query)
+
+	facets := &model.Facets{Facets: make([]model.Facet, len(specs))}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM cars`+where, args...).Scan(&facets.Total); err != nil {
+		return nil, err
+	}
+
+	for i, spec := range specs {
+		// the facet fields are checked against a fixed list, so they are safe
+		// to use as column names
+		facet := model.Facet{Field: spec.Field}
+		var err error
+		if model.IsTermsFacet(spec.Field) {
+			facet.Terms, err = s.termsFacet(where, args, spec)
+		} else {
+			facet.Ranges, err = s.rangeFacet(where, args, spec)
+		}
+		if err != nil {
+			return nil, err
+		}
+		facets.Facets[i] = facet
+	}
+	return facets, nil
+}
+
+func (s *sqlCars) termsFacet(where string, args []any, spec model.FacetSpec) ([]model.TermCount, error) {
+	column := spec.Field
+	nonEmpty := column + " <> ''"
+	if where == "" {
+		where = " WHERE " + nonEmpty
+	} else {
+		where += " AND " + nonEmpty
+	}
+
+	statement := `SELECT ` + column + `, COUNT(*) AS n FROM cars` + where +
+		` GROUP BY ` + column + ` ORDER BY n DESC, ` + column
+	if spec.Size > 0 {
+		statement += ` LIMIT ?`
+		args = append(append([]any(nil), args...), spec.Size)
+	}
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []model.TermCount{}
+	for rows.Next() {
+		var term model.TermCount
+		if err := rows.Scan(&term.Value, &term.Count); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+func (s *sqlCars) rangeFacet(where string, args []any, spec model.FacetSpec) ([]model.RangeBucket, error) {
+	// number the buckets the way model.Bucket does
+	var cases strings.Builder
+	bucketArgs := make([]any, 0, len(spec.Bounds)+len(args))
+	cases.WriteString("CASE")
+	for i, bound := range spec.Bounds {
+		cases.WriteString(" WHEN " + spec.Field + " < ? THEN ?")
+		bucketArgs = append(bucketArgs, bound, i)
+	}
+	cases.WriteString(" ELSE ? END")
+	bucketArgs = append(bucketArgs, len(spec.Bounds))
+
+	rows, err := s.db.Query(`SELECT `+cases.String()+` AS bucket, COUNT(*) FROM cars`+where+` GROUP BY bucket`,
+		append(bucketArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := model.NewRangeBuckets(spec.Bounds)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		buckets[bucket].Count = count
+	}
+	return buckets, rows.Err()
+}
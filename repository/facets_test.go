@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStoreFacets(t *testing.T) {
+	store := newSQLStore(t)
+	cars := []*model.Car{
+		{ID: "a", Make: "Toyota", Color: "Red", Category: "Sedan", Year: 2005, Price: 900000, Mileage: 120000},
+		{ID: "b", Make: "Toyota", Color: "", Category: "Truck", Year: 2010, Price: 2500000, Mileage: 50000},
+		{ID: "c", Make: "Honda", Color: "Blue", Category: "Sedan", Year: 2019, Price: 2000000, Mileage: 9000},
+		{ID: "d", Make: "Kia", Color: "Red", Category: "SUV", Year: 2020, Price: 3000000, Mileage: 0},
+		{ID: "e", Make: "Honda", Color: "red", Category: "Sedan", Year: 2024, Price: 6000000, Mileage: 10},
+	}
+	for _, car := range cars {
+		assert.NoError(t, store.Save(car.ID, car))
+	}
+
+	var _ repository.Aggregator = store
+
+	yearMin := 2006
+	tests := []struct {
+		name  string
+		query model.Query
+		specs []model.FacetSpec
+	}{
+		{"Every facet", model.Query{}, []model.FacetSpec{
+			{Field: "make", Size: 10},
+			{Field: "color"},
+			{Field: "category", Size: 1},
+			{Field: "year", Bounds: model.DefaultFacetBounds["year"]},
+			{Field: "price", Bounds: model.DefaultFacetBounds["price"]},
+			{Field: "mileage", Bounds: model.DefaultFacetBounds["mileage"]},
+		}},
+		{"Filtered", model.Query{YearMin: &yearMin, Category: "sedan"}, []model.FacetSpec{
+			{Field: "make"},
+			{Field: "price", Bounds: []int{2000000}},
+		}},
+		{"Nothing matches", model.Query{Make: "Ford"}, []model.FacetSpec{
+			{Field: "make"},
+			{Field: "year", Bounds: []int{2000}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the database agrees with counting in memory
+			counter := model.NewFacetCounter(tt.specs)
+			for _, car := range cars {
+				if tt.query.Matches(car) {
+					counter.Add(car)
+				}
+			}
+
+			facets, err := store.Facets(tt.query, tt.specs)
+			assert.NoError(t, err)
+			assert.Equal(t, counter.Facets(), facets)
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+)
+
+const (
+	walFileName      = "cars.wal"
+	snapshotFileName = "cars.snapshot"
+	historyFileName  = "cars.history"
+)
+
+const (
+	opSave   = "save"
+	opUpdate = "update"
+	opDelete = "delete"
+	opBatch  = "batch"
+	// opHistory records a revision for a HistoryStore over the FileStore
+	opHistory = "history"
+)
+
+// walRecord is a single line of the write-ahead log. A batch record carries the
+// writes of a transaction, so a torn line loses all of them or none. History
+// records are numbered so that replay can skip those Compact already moved to
+// the history file.
+type walRecord struct {
+	Op    string        `json:"op"`
+	Key   string        `json:"key,omitempty"`
+	Car   *model.Car    `json:"car,omitempty"`
+	Batch []walRecord   `json:"batch,omitempty"`
+	Seq   int64         `json:"seq,omitempty"`
+	Entry *historyEntry `json:"entry,omitempty"`
+}
+
+type FileStoreOptions struct {
+	// CompactInterval is how often the log is folded into a snapshot. Zero disables
+	// background compaction; Compact can still be called directly.
+	CompactInterval time.Duration
+	// SyncWrites fsyncs the log after every record.
+	SyncWrites bool
+}
+
+// FileStore is a Storage that keeps cars in memory and makes them durable by
+// appending every write to a log on disk, which is replayed on startup.
+//
+// The revisions of a HistoryStore over the FileStore are logged in the same
+// record as the writes of their transaction. They are not kept in memory:
+// Compact appends them to a history file of their own, and they are read back
+// from there and the log when they are asked for.
+type FileStore struct {
+	mem  *Repo
+	dir  string
+	opts FileStoreOptions
+
+	mu      sync.Mutex
+	wal     *os.File
+	history *os.File
+
+	historySeq int64          // number of the last history record
+	revisions  map[string]int // number of the last revision of each car
+	logged     []walRecord    // history records in the log but not yet in the history file
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	s := &FileStore{
+		mem:       NewRepo(),
+		dir:       dir,
+		opts:      opts,
+		revisions: make(map[string]int),
+		done:      make(chan struct{}),
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	historyValid, err := readRecords(s.historyPath(), func(rec walRecord) error {
+		s.noteHistory(rec)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	valid, err := readRecords(s.walPath(), s.apply)
+	if err != nil {
+		return nil, fmt.Errorf("reading write-ahead log: %w", err)
+	}
+
+	// cut off anything after the last complete record so new appends start on a clean line
+	s.history, err = openLog(s.historyPath(), historyValid)
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	s.wal, err = openLog(s.walPath(), valid)
+	if err != nil {
+		s.history.Close()
+		return nil, fmt.Errorf("opening write-ahead log: %w", err)
+	}
+
+	if opts.CompactInterval > 0 {
+		s.wg.Add(1)
+		go s.compactLoop(opts.CompactInterval)
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Save(key string, object *model.Car) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := object.Clone()
+	next.Version = 1
+	if stored, err := s.mem.Get(key); err == nil {
+		next.Version = stored.Version + 1
+	}
+
+	if err := s.append(walRecord{Op: opSave, Key: key, Car: next}); err != nil {
+		return err
+	}
+	s.mem.put(key, next)
+	object.Version = next.Version
+	return nil
+}
+
+func (s *FileStore) Get(key string) (*model.Car, error) {
+	return s.mem.Get(key)
+}
+
+func (s *FileStore) GetAll() ([]*model.Car, error) {
+	return s.mem.GetAll()
+}
+
+func (s *FileStore) Find(query model.Query) ([]*model.Car, error) {
+	return s.mem.Find(query)
+}
+
+func (s *FileStore) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	return s.mem.Iterate(query, fn)
+}
+
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// only log writes that will succeed so replay never trips over them
+	if _, err := s.mem.Get(key); err != nil {
+		return err
+	}
+
+	if err := s.append(walRecord{Op: opDelete, Key: key}); err != nil {
+		return err
+	}
+	s.mem.remove(key)
+	return nil
+}
+
+func (s *FileStore) Update(key string, object *model.Car) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.mem.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(stored, object); err != nil {
+		return err
+	}
+
+	next := object.Clone()
+	next.Version = stored.Version + 1
+
+	if err := s.append(walRecord{Op: opUpdate, Key: key, Car: next}); err != nil {
+		return err
+	}
+	s.mem.put(key, next)
+	object.Version = next.Version
+	return nil
+}
+
+// Begin starts a transaction whose writes are logged as a single record when
+// it commits.
+func (s *FileStore) Begin() (Tx, error) {
+	return &fileTx{
+		repoTx: s.mem.begin(),
+		store:  s,
+	}, nil
+}
+
+type fileTx struct {
+	*repoTx
+	store *FileStore
+
+	mu      sync.Mutex
+	history []historyEntry // revisions to log with the writes
+}
+
+// appendHistory keeps entry to log with the writes when the transaction
+// commits.
+func (tx *fileTx) appendHistory(entry historyEntry) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.history = append(tx.history, entry)
+	return nil
+}
+
+func (tx *fileTx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.store.mu.Lock()
+	defer tx.store.mu.Unlock()
+
+	return tx.repoTx.commit(func(keys []string, writes map[string]*model.Car) error {
+		batch := walRecord{Op: opBatch}
+		for _, key := range keys {
+			if car := writes[key]; car != nil {
+				batch.Batch = append(batch.Batch, walRecord{Op: opSave, Key: key, Car: car})
+			} else {
+				batch.Batch = append(batch.Batch, walRecord{Op: opDelete, Key: key})
+			}
+		}
+
+		// number the revisions after the ones logged before
+		history := make([]walRecord, len(tx.history))
+		numbers := make(map[string]int)
+		for i := range tx.history {
+			entry := tx.history[i]
+			number, ok := numbers[entry.Key]
+			if !ok {
+				number = tx.store.revisions[entry.Key]
+			}
+			entry.Number = number + 1
+			numbers[entry.Key] = entry.Number
+			history[i] = walRecord{Op: opHistory, Seq: tx.store.historySeq + int64(i) + 1, Entry: &entry}
+		}
+		batch.Batch = append(batch.Batch, history...)
+
+		if err := tx.store.append(batch); err != nil {
+			return err
+		}
+		for _, rec := range history {
+			tx.store.noteHistory(rec)
+			tx.store.logged = append(tx.store.logged, rec)
+		}
+		return nil
+	})
+}
+
+// readHistory reads the revisions of key from the history file, then from the
+// history records still in the log. Compact appends those to the history file
+// before it drops them, so every record is in one or the other, and the ones
+// in both are told apart by their numbers. Only the copying of s.logged holds
+// s.mu.
+func (s *FileStore) readHistory(key string, fn func(entry historyEntry) error) error {
+	s.mu.Lock()
+	logged := s.logged
+	s.mu.Unlock()
+
+	var seq int64
+	last := 0
+	read := func(rec walRecord) error {
+		seq = rec.Seq
+		if rec.Entry.Key != key {
+			return nil
+		}
+		entry := *rec.Entry
+		if entry.Number == 0 {
+			// logged before revisions were numbered
+			entry.Number = last + 1
+		}
+		last = entry.Number
+		return fn(entry)
+	}
+
+	if _, err := readRecords(s.historyPath(), read); err != nil {
+		return err
+	}
+	for _, rec := range logged {
+		if rec.Seq <= seq {
+			continue
+		}
+		if err := read(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noteHistory takes note of the number of a history record in the history file
+// or the log. Records logged before revisions were numbered are numbered here.
+func (s *FileStore) noteHistory(rec walRecord) {
+	if rec.Entry.Number == 0 {
+		rec.Entry.Number = s.revisions[rec.Entry.Key] + 1
+	}
+	s.historySeq = rec.Seq
+	s.revisions[rec.Entry.Key] = rec.Entry.Number
+}
+
+// Compact writes the current state to a new snapshot, moves the history
+// records to the history file and truncates the log.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write the snapshot to a temp file first so a crash never leaves a partial snapshot behind
+	tmp, err := os.CreateTemp(s.dir, snapshotFileName+".*")
+	if err != nil {
+		return fmt.Errorf("creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	s.mem.RLock()
+	err = json.NewEncoder(tmp).Encode(s.mem.db)
+	s.mem.RUnlock()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.snapshotPath()); err != nil {
+		return fmt.Errorf("installing snapshot: %w", err)
+	}
+
+	// a crash before the log is truncated replays records already in the
+	// history file, which their numbers tell apart; a record leaves s.logged
+	// as soon as it is written, so a failure later on does not append it twice
+	for len(s.logged) > 0 {
+		if err := writeRecord(s.history, s.logged[0]); err != nil {
+			return fmt.Errorf("appending to history: %w", err)
+		}
+		s.logged = s.logged[1:]
+	}
+	if err := s.history.Sync(); err != nil {
+		return fmt.Errorf("syncing history: %w", err)
+	}
+
+	// everything in the log is now covered by the snapshot
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	return s.wal.Sync()
+}
+
+// Close stops background compaction and closes the log.
+func (s *FileStore) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.wal.Close(), s.history.Close())
+}
+
+func (s *FileStore) compactLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Compact(); err != nil {
+				log.Printf("error while compacting the write-ahead log. err: %v\n", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *FileStore) append(rec walRecord) error {
+	if err := writeRecord(s.wal, rec); err != nil {
+		return fmt.Errorf("appending to write-ahead log: %w", err)
+	}
+	if s.opts.SyncWrites {
+		if err := s.wal.Sync(); err != nil {
+			return fmt.Errorf("syncing write-ahead log: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	f, err := os.Open(s.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&s.mem.db); err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	s.mem.indexes.rebuild(s.mem.db)
+	return nil
+}
+
+// readRecords hands fn the records of the log at path and returns the length
+// of the log up to the end of the last complete record. A missing log has no
+// records.
+func readRecords(path string, fn func(rec walRecord) error) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var valid int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a trailing line without a newline is a write torn by a crash, drop it
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return 0, fmt.Errorf("decoding record: %w", err)
+		}
+		if err := fn(rec); err != nil {
+			return 0, err
+		}
+		valid += int64(len(line))
+	}
+}
+
+// openLog opens the log at path for appending, cut to length valid.
+func openLog(path string, valid int64) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeRecord appends rec to a log as a single line.
+func writeRecord(f *os.File, rec walRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding log record: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// apply replays one log record into memory.
+func (s *FileStore) apply(rec walRecord) error {
+	switch rec.Op {
+	case opSave, opUpdate:
+		s.mem.set(rec.Key, rec.Car)
+	case opDelete:
+		s.mem.set(rec.Key, nil)
+	case opBatch:
+		for _, write := range rec.Batch {
+			if err := s.apply(write); err != nil {
+				return err
+			}
+		}
+	case opHistory:
+		// records up to historySeq were moved to the history file
+		if rec.Seq > s.historySeq {
+			s.noteHistory(rec)
+			s.logged = append(s.logged, rec)
+		}
+	default:
+		return fmt.Errorf("unknown write-ahead log operation %q", rec.Op)
+	}
+	return nil
+}
+
+func (s *FileStore) walPath() string {
+	return filepath.Join(s.dir, walFileName)
+}
+
+func (s *FileStore) snapshotPath() string {
+	return filepath.Join(s.dir, snapshotFileName)
+}
+
+func (s *FileStore) historyPath() string {
+	return filepath.Join(s.dir, historyFileName)
+}
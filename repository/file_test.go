@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFileStore(t *testing.T, dir string) *repository.FileStore {
+	t.Helper()
+
+	store, err := repository.NewFileStore(dir, repository.FileStoreOptions{})
+	assert.NoError(t, err)
+	return store
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	t.Run("Writes survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+
+		civic := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2019, Price: 2000000}
+		camry := &model.Car{ID: "camry", Make: "Toyota", Model: "Camry", Year: 2020, Price: 2500000}
+		accord := &model.Car{ID: "civic", Make: "Honda", Model: "Accord", Year: 2021, Price: 3000000}
+
+		assert.NoError(t, store.Save(civic.ID, civic))
+		assert.NoError(t, store.Save(camry.ID, camry))
+		assert.NoError(t, store.Update(civic.ID, accord))
+		assert.NoError(t, store.Delete(camry.ID))
+		assert.NoError(t, store.Close())
+
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+
+		object, err := reopened.Get(civic.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, accord, object)
+
+		cars, err := reopened.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+	})
+
+	t.Run("Torn trailing record is discarded", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}
+		assert.NoError(t, store.Save(car.ID, car))
+		assert.NoError(t, store.Close())
+
+		// simulate a crash in the middle of appending a record
+		wal, err := os.OpenFile(filepath.Join(dir, "cars.wal"), os.O_WRONLY|os.O_APPEND, 0o644)
+		assert.NoError(t, err)
+		_, err = wal.WriteString(`{"op":"save","key":"half`)
+		assert.NoError(t, err)
+		assert.NoError(t, wal.Close())
+
+		reopened := newFileStore(t, dir)
+		other := &model.Car{ID: "camry", Make: "Toyota", Model: "Camry"}
+		assert.NoError(t, reopened.Save(other.ID, other))
+		assert.NoError(t, reopened.Close())
+
+		final := newFileStore(t, dir)
+		defer final.Close()
+
+		cars, err := final.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 2)
+	})
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	dir := t.TempDir()
+	store := newFileStore(t, dir)
+
+	car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}
+	assert.NoError(t, store.Save(car.ID, car))
+	assert.NoError(t, store.Compact())
+
+	info, err := os.Stat(filepath.Join(dir, "cars.wal"))
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// writes after a compaction land in the fresh log on top of the snapshot
+	other := &model.Car{ID: "camry", Make: "Toyota", Model: "Camry"}
+	assert.NoError(t, store.Save(other.ID, other))
+	assert.NoError(t, store.Close())
+
+	reopened := newFileStore(t, dir)
+	defer reopened.Close()
+
+	object, err := reopened.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, car, object)
+
+	cars, err := reopened.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, cars, 2)
+}
@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// History is implemented by storage that remembers past revisions of cars.
+type History interface {
+	// Revisions lists every recorded write to the car, oldest first.
+	Revisions(key string) ([]*model.Revision, error)
+	// Revision returns the revision with the given number.
+	Revision(key string, number int) (*model.Revision, error)
+	// AsOf returns the car as it was at the given time, or utils.ErrNotFound
+	// when it did not exist then.
+	AsOf(key string, at time.Time) (*model.Car, error)
+}
+
+// HistoryStore is a Storage that records a revision for every write it passes
+// on to the underlying storage. Updates that set or clear DeletedAt are
+// recorded as deletes and restores, and Delete as a purge. The actor of a
+// revision is the UpdatedBy of the car written; purges carry no car and so have
+// no actor. The revisions of a car are numbered from 1 in the order their
+// writes commit, and keep their numbers.
+//
+// Every write goes through a transaction of the underlying storage. The SQL
+// and file storages keep each revision in the transaction of its write, so the
+// two are stored together or not at all, and read the revisions back when they
+// are asked for. Over the other storages revisions are kept in memory and
+// start empty, whatever the storage already holds; only the last historyLimit
+// of each car are kept, and a purge drops all but its own.
+type HistoryStore struct {
+	Storage
+	now     func() time.Time
+	backend historyLog // nil when the storage cannot keep the revisions
+
+	// the revisions kept in memory; a transaction holds the locks of the cars
+	// it wrote over its commit and their recording, so the revisions of a car
+	// are recorded in the order its writes commit
+	mu      sync.RWMutex
+	entries map[string][]historyEntry
+	locks   keyLocks
+}
+
+// historyLimit is the number of revisions of a car kept in memory.
+const historyLimit = 100
+
+// historyLog is implemented by storages that keep the revisions a HistoryStore
+// records. Their transactions implement historyWriter.
+type historyLog interface {
+	// readHistory hands fn the entries of the car under key in number order.
+	readHistory(key string, fn func(entry historyEntry) error) error
+}
+
+// historyWriter is implemented by the transactions of a historyLog.
+type historyWriter interface {
+	// appendHistory keeps entry with the writes of the transaction, numbered
+	// after the last entry of its car.
+	appendHistory(entry historyEntry) error
+}
+
+// historyEntry is a write as a HistoryStore records it. The op of a revision
+// follows from the entry before it, so it is not stored.
+type historyEntry struct {
+	Key    string     `json:"key"`
+	Number int        `json:"number"`
+	Op     string     `json:"op"` // opSave, opUpdate or opDelete
+	At     time.Time  `json:"at"`
+	Car    *model.Car `json:"car,omitempty"`
+}
+
+// NewHistoryStore records the writes to storage, timestamped by now.
+func NewHistoryStore(storage Storage, now func() time.Time) *HistoryStore {
+	h := &HistoryStore{
+		Storage: storage,
+		now:     now,
+		entries: make(map[string][]historyEntry),
+	}
+	if backend, ok := As[historyLog](storage); ok {
+		h.backend = backend
+	}
+	return h
+}
+
+func (h *HistoryStore) Save(key string, object *model.Car) error {
+	return h.write(func(tx Tx) error {
+		return tx.Save(key, object)
+	})
+}
+
+func (h *HistoryStore) Update(key string, object *model.Car) error {
+	return h.write(func(tx Tx) error {
+		return tx.Update(key, object)
+	})
+}
+
+func (h *HistoryStore) Delete(key string) error {
+	return h.write(func(tx Tx) error {
+		return tx.Delete(key)
+	})
+}
+
+// write makes a single write in a transaction of its own, so it is recorded
+// like any other. A write on its own goes ahead whatever is written alongside
+// it, so the transaction is run again when it conflicts with another.
+func (h *HistoryStore) write(fn func(tx Tx) error) error {
+	for {
+		err := WithTx(h, fn)
+		if !errors.Is(err, utils.ErrConflict) {
+			return err
+		}
+	}
+}
+
+// Unwrap returns the underlying storage.
+func (h *HistoryStore) Unwrap() Storage {
+	return h.Storage
+}
+
+// Begin starts a transaction on the underlying storage whose writes are
+// recorded with it.
+func (h *HistoryStore) Begin() (Tx, error) {
+	tx, err := h.Storage.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	htx := &historyTx{Tx: tx, history: h}
+	if h.backend != nil {
+		writer, ok := asTx[historyWriter](tx)
+		if !ok {
+			_ = tx.Rollback()
+			return nil, errors.New("the transaction cannot keep revisions")
+		}
+		htx.writer = writer
+	}
+	return htx, nil
+}
+
+func (h *HistoryStore) Revisions(key string) ([]*model.Revision, error) {
+	revisions, err := h.revisions(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return revisions, nil
+}
+
+func (h *HistoryStore) Revision(key string, number int) (*model.Revision, error) {
+	revisions, err := h.revisions(key)
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(revisions), func(i int) bool { return revisions[i].Number >= number })
+	if i == len(revisions) || revisions[i].Number != number {
+		return nil, fmt.Errorf("%w: car %s has no revision %d", utils.ErrNotFound, key, number)
+	}
+	return revisions[i], nil
+}
+
+func (h *HistoryStore) AsOf(key string, at time.Time) (*model.Car, error) {
+	revisions, err := h.revisions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the last revision made no later than at; writes can commit in a
+	// different order than they were timestamped, so every one is looked at
+	var found *model.Revision
+	for _, revision := range revisions {
+		if !revision.At.After(at) {
+			found = revision
+		}
+	}
+	if found == nil || found.Car == nil || found.Car.Deleted() {
+		return nil, fmt.Errorf("%w: car %s did not exist at %s", utils.ErrNotFound, key, at.Format(time.RFC3339))
+	}
+	return found.Car, nil
+}
+
+// revisions reads the entries of key and tells their ops apart. The cars are
+// the caller's to keep.
+func (h *HistoryStore) revisions(key string) ([]*model.Revision, error) {
+	var entries []historyEntry
+	if h.backend != nil {
+		err := h.backend.readHistory(key, func(entry historyEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading revisions of car %s: %w", key, err)
+		}
+	} else {
+		h.mu.RLock()
+		for _, entry := range h.entries[key] {
+			entry.Car = entry.Car.Clone()
+			entries = append(entries, entry)
+		}
+		h.mu.RUnlock()
+	}
+
+	revisions := make([]*model.Revision, len(entries))
+	var last *model.Revision
+	for i, entry := range entries {
+		revision := &model.Revision{
+			Number: entry.Number,
+			Op:     revisionOp(entry, last),
+			At:     entry.At,
+			Car:    entry.Car,
+		}
+		if entry.Car != nil {
+			revision.Actor = entry.Car.UpdatedBy
+		}
+		revisions[i] = revision
+		last = revision
+	}
+	return revisions, nil
+}
+
+// remember numbers the entries of a committed transaction and keeps them in
+// memory. The caller holds the locks of their cars.
+func (h *HistoryStore) remember(entries []historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, entry := range entries {
+		kept := h.entries[entry.Key]
+		entry.Number = 1
+		if len(kept) > 0 {
+			entry.Number = kept[len(kept)-1].Number + 1
+		}
+
+		switch {
+		case entry.Op == opDelete:
+			kept = nil
+		case len(kept) == historyLimit:
+			kept = kept[1:]
+		}
+		h.entries[entry.Key] = append(kept, entry)
+	}
+}
+
+// revisionOp tells what entry did to the car, given the revision before it.
+// Updates that set or clear DeletedAt are deletes and restores.
+func revisionOp(entry historyEntry, last *model.Revision) string {
+	switch entry.Op {
+	case opDelete:
+		return model.RevisionPurge
+	case opSave:
+		// saving a missing car starts its versions over
+		if entry.Car.Version == 1 {
+			return model.RevisionCreate
+		}
+		return model.RevisionUpdate
+	}
+
+	wasDeleted := last != nil && last.Car != nil && last.Car.Deleted()
+	switch {
+	case entry.Car.Deleted() && !wasDeleted:
+		return model.RevisionDelete
+	case !entry.Car.Deleted() && wasDeleted:
+		return model.RevisionRestore
+	}
+	return model.RevisionUpdate
+}
+
+// historyTx records the writes of a transaction on the underlying storage.
+// Over a storage that keeps the revisions each one goes into the transaction
+// with its write; otherwise they are kept in memory once it commits.
+type historyTx struct {
+	Tx
+	history *HistoryStore
+	writer  historyWriter // nil when the revisions are kept in memory
+
+	mu      sync.Mutex
+	pending []historyEntry
+}
+
+func (tx *historyTx) Save(key string, object *model.Car) error {
+	if err := tx.Tx.Save(key, object); err != nil {
+		return err
+	}
+	return tx.record(historyEntry{Key: key, Op: opSave, Car: object.Clone()})
+}
+
+func (tx *historyTx) Update(key string, object *model.Car) error {
+	if err := tx.Tx.Update(key, object); err != nil {
+		return err
+	}
+	return tx.record(historyEntry{Key: key, Op: opUpdate, Car: object.Clone()})
+}
+
+func (tx *historyTx) Delete(key string) error {
+	if err := tx.Tx.Delete(key); err != nil {
+		return err
+	}
+	return tx.record(historyEntry{Key: key, Op: opDelete})
+}
+
+// record timestamps entry and hands it to the storage, or keeps it for the
+// commit.
+func (tx *historyTx) record(entry historyEntry) error {
+	entry.At = tx.history.now()
+	if tx.writer != nil {
+		return tx.writer.appendHistory(entry)
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.pending = append(tx.pending, entry)
+	return nil
+}
+
+func (tx *historyTx) Commit() error {
+	if tx.writer != nil {
+		return tx.Tx.Commit()
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	keys := make([]string, len(tx.pending))
+	for i, entry := range tx.pending {
+		keys[i] = entry.Key
+	}
+	defer tx.history.locks.lock(keys...)()
+
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	tx.history.remember(tx.pending)
+	return nil
+}
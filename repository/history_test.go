@@ -0,0 +1,351 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryStore(t *testing.T) {
+	start := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	store := repository.NewHistoryStore(repository.NewRepo(), clock)
+
+	// one write a day
+	car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Price: 2000000, UpdatedBy: "alice"}
+	assert.NoError(t, store.Save(car.ID, car))
+
+	now = now.Add(24 * time.Hour)
+	car = &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Price: 1900000, UpdatedBy: "bob"}
+	assert.NoError(t, store.Update(car.ID, car))
+
+	now = now.Add(24 * time.Hour)
+	deletedAt := now
+	car = &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Price: 1900000, DeletedAt: &deletedAt}
+	assert.NoError(t, store.Update(car.ID, car))
+
+	now = now.Add(24 * time.Hour)
+	car.DeletedAt = nil
+	assert.NoError(t, store.Update(car.ID, car))
+
+	t.Run("Records every write", func(t *testing.T) {
+		revisions, err := store.Revisions("civic")
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 4)
+
+		assert.Equal(t, model.RevisionCreate, revisions[0].Op)
+		assert.Equal(t, "alice", revisions[0].Actor)
+		assert.Equal(t, start, revisions[0].At)
+		assert.Equal(t, int64(1), revisions[0].Car.Version)
+
+		assert.Equal(t, model.RevisionUpdate, revisions[1].Op)
+		assert.Equal(t, "bob", revisions[1].Actor)
+		assert.Equal(t, 1900000, revisions[1].Car.Price)
+
+		assert.Equal(t, model.RevisionDelete, revisions[2].Op)
+		assert.NotNil(t, revisions[2].Car.DeletedAt)
+
+		assert.Equal(t, model.RevisionRestore, revisions[3].Op)
+	})
+
+	t.Run("Fetches one revision", func(t *testing.T) {
+		revision, err := store.Revision("civic", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, revision.Number)
+		assert.Equal(t, int64(2), revision.Car.Version)
+
+		_, err = store.Revision("civic", 5)
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+
+		_, err = store.Revisions("missing")
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+	})
+
+	t.Run("Reads the car at a point in time", func(t *testing.T) {
+		object, err := store.AsOf("civic", start.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 2000000, object.Price)
+
+		object, err = store.AsOf("civic", start.Add(24*time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1900000, object.Price)
+
+		_, err = store.AsOf("civic", start.Add(-time.Hour))
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+
+		_, err = store.AsOf("civic", start.Add(48*time.Hour))
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+
+		object, err = store.AsOf("civic", start.Add(72*time.Hour))
+		assert.NoError(t, err)
+		assert.Nil(t, object.DeletedAt)
+	})
+
+	t.Run("Purging keeps only the purge in memory", func(t *testing.T) {
+		now = now.Add(24 * time.Hour)
+		assert.NoError(t, store.Delete("civic"))
+
+		revisions, err := store.Revisions("civic")
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 1)
+		assert.Equal(t, 5, revisions[0].Number)
+		assert.Equal(t, model.RevisionPurge, revisions[0].Op)
+		assert.Nil(t, revisions[0].Car)
+
+		_, err = store.AsOf("civic", now)
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+	})
+
+	t.Run("Saving a purged car starts over", func(t *testing.T) {
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}
+		assert.NoError(t, store.Save(car.ID, car))
+
+		revision, err := store.Revision("civic", 6)
+		assert.NoError(t, err)
+		assert.Equal(t, model.RevisionCreate, revision.Op)
+		assert.Equal(t, int64(1), revision.Car.Version)
+	})
+
+	t.Run("Failed writes are not recorded", func(t *testing.T) {
+		stale := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Version: 9}
+		assert.ErrorIs(t, store.Update(stale.ID, stale), utils.ErrPreconditionFailed)
+
+		revisions, err := store.Revisions("civic")
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 2)
+	})
+
+	t.Run("Keeps the last 100 revisions of a car in memory", func(t *testing.T) {
+		car := &model.Car{ID: "accord", Make: "Honda", Model: "Accord"}
+		assert.NoError(t, store.Save(car.ID, car))
+		for i := 0; i < 149; i++ {
+			car.Version = 0
+			assert.NoError(t, store.Update(car.ID, car))
+		}
+
+		revisions, err := store.Revisions("accord")
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 100)
+		assert.Equal(t, 51, revisions[0].Number)
+		assert.Equal(t, 150, revisions[99].Number)
+	})
+}
+
+// holdingStorage holds the first transaction that commits an update, once it
+// has, until release is closed, as if the writer was descheduled right after
+// the commit.
+type holdingStorage struct {
+	repository.Storage
+	held    chan struct{}
+	release chan struct{}
+	commits int32
+}
+
+func (s *holdingStorage) Begin() (repository.Tx, error) {
+	tx, err := s.Storage.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &holdingTx{Tx: tx, storage: s}, nil
+}
+
+type holdingTx struct {
+	repository.Tx
+	storage *holdingStorage
+	updated bool
+}
+
+func (tx *holdingTx) Update(key string, object *model.Car) error {
+	tx.updated = true
+	return tx.Tx.Update(key, object)
+}
+
+func (tx *holdingTx) Commit() error {
+	err := tx.Tx.Commit()
+	if tx.updated && atomic.AddInt32(&tx.storage.commits, 1) == 1 {
+		close(tx.storage.held)
+		<-tx.storage.release
+	}
+	return err
+}
+
+func TestHistoryStoreOrder(t *testing.T) {
+	start := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	now := start
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Hour)
+		return now
+	}
+	backend := &holdingStorage{Storage: repository.NewRepo(), held: make(chan struct{}), release: make(chan struct{})}
+	store := repository.NewHistoryStore(backend, clock)
+	assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Price: 2000000}))
+
+	// version 2 commits first, and version 3 commits while it is held
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Price: 1900000}))
+	}()
+	<-backend.held
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Price: 1800000}))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	revisions, err := store.Revisions("civic")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+	for i, revision := range revisions {
+		assert.Equal(t, i+1, revision.Number)
+		assert.Equal(t, int64(i+1), revision.Car.Version)
+	}
+	assert.Equal(t, 1900000, revisions[1].Car.Price)
+
+	// every revision keeps the time of its write
+	assert.Equal(t, start.Add(time.Hour), revisions[0].At)
+	assert.Equal(t, start.Add(2*time.Hour), revisions[1].At)
+	assert.Equal(t, start.Add(3*time.Hour), revisions[2].At)
+
+	object, err := store.AsOf("civic", start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1900000, object.Price)
+}
+
+// recordHistory makes a revision of each kind on a civic over storage.
+func recordHistory(t *testing.T, storage repository.Storage, clock func() time.Time) {
+	t.Helper()
+
+	store := repository.NewHistoryStore(storage, clock)
+	car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", UpdatedBy: "alice"}
+	assert.NoError(t, store.Save(car.ID, car))
+	deletedAt := clock()
+	car.DeletedAt = &deletedAt
+	assert.NoError(t, store.Update(car.ID, car))
+	car.DeletedAt = nil
+	assert.NoError(t, store.Update(car.ID, car))
+	assert.NoError(t, store.Delete(car.ID))
+}
+
+// assertHistory checks that storage holds the revisions made by recordHistory.
+func assertHistory(t *testing.T, storage repository.Storage, start time.Time) {
+	t.Helper()
+
+	store := repository.NewHistoryStore(storage, time.Now)
+	revisions, err := store.Revisions("civic")
+	assert.NoError(t, err)
+	var ops []string
+	for i, revision := range revisions {
+		assert.Equal(t, i+1, revision.Number)
+		ops = append(ops, revision.Op)
+	}
+	assert.Equal(t, []string{model.RevisionCreate, model.RevisionDelete, model.RevisionRestore, model.RevisionPurge}, ops)
+	assert.Equal(t, "alice", revisions[0].Actor)
+	assert.True(t, start.Equal(revisions[0].At))
+
+	object, err := store.AsOf("civic", start)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), object.Version)
+}
+
+func TestHistoryStorePersistence(t *testing.T) {
+	start := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	// one write an hour from start
+	newClock := func() func() time.Time {
+		now := start.Add(-time.Hour)
+		return func() time.Time {
+			now = now.Add(time.Hour)
+			return now
+		}
+	}
+
+	t.Run("Revisions survive a restart of the file storage", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+		recordHistory(t, store, newClock())
+		assert.NoError(t, store.Close())
+
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+		assertHistory(t, reopened, start)
+	})
+
+	t.Run("Compaction keeps the revisions", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+		recordHistory(t, store, newClock())
+		assert.NoError(t, store.Compact())
+		assertHistory(t, store, start)
+		assert.NoError(t, store.Close())
+
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+		assertHistory(t, reopened, start)
+	})
+
+	t.Run("A compaction cut short is not replayed twice", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+		recordHistory(t, store, newClock())
+		wal, err := os.ReadFile(filepath.Join(dir, "cars.wal"))
+		assert.NoError(t, err)
+		assert.NoError(t, store.Compact())
+		assert.NoError(t, store.Close())
+
+		// put back the log as if the compaction crashed before truncating it
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "cars.wal"), wal, 0o644))
+
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+		assertHistory(t, reopened, start)
+	})
+
+	t.Run("Revisions survive a restart of the SQL storage", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cars.db")
+		open := func() *repository.SQLStore {
+			db, err := sql.Open("sqlite", repository.SQLiteDSN(path))
+			assert.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			store, err := repository.NewSQLStore(db)
+			assert.NoError(t, err)
+			return store
+		}
+
+		recordHistory(t, open(), newClock())
+		assertHistory(t, open(), start)
+	})
+
+	t.Run("A write fails with its revision", func(t *testing.T) {
+		db, err := sql.Open("sqlite", repository.SQLiteDSN(filepath.Join(t.TempDir(), "cars.db")))
+		assert.NoError(t, err)
+		defer db.Close()
+		storage, err := repository.NewSQLStore(db)
+		assert.NoError(t, err)
+		store := repository.NewHistoryStore(storage, newClock())
+
+		// the revision cannot be stored, so neither is the car
+		_, err = db.Exec(`DROP TABLE car_revisions`)
+		assert.NoError(t, err)
+		assert.Error(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}))
+
+		_, err = storage.Get("civic")
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+	})
+}
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/DalvinCodes/cars/model"
+)
+
+// Plan describes how a Repo answers a query.
+type Plan struct {
+	// Index is the field whose index the cars are read from, or empty when
+	// every car is scanned.
+	Index string
+	// Candidates is how many cars are read and checked against the query.
+	Candidates int
+}
+
+// termIndex maps the case-folded value of a text field to the keys of the cars
+// holding it.
+type termIndex struct {
+	field string
+	value func(car *model.Car) string
+	query func(query model.Query) string
+	keys  map[string]map[string]struct{}
+}
+
+// rangeIndex keeps the keys of the cars sorted by a number field.
+type rangeIndex struct {
+	field   string
+	value   func(car *model.Car) int
+	bounds  func(query model.Query) (lo, hi *int)
+	entries []rangeEntry // by value, then key
+}
+
+type rangeEntry struct {
+	value int
+	key   string
+}
+
+// carIndexes holds the secondary indexes of a Repo. They are changed under the
+// Repo's write lock together with db, so readers always find them in step.
+type carIndexes struct {
+	terms  []*termIndex
+	ranges []*rangeIndex
+}
+
+func newCarIndexes() *carIndexes {
+	return &carIndexes{
+		terms: []*termIndex{
+			newTermIndex("make", func(c *model.Car) string { return c.Make }, func(q model.Query) string { return q.Make }),
+			newTermIndex("model", func(c *model.Car) string { return c.Model }, func(q model.Query) string { return q.Model }),
+			newTermIndex("color", func(c *model.Car) string { return c.Color }, func(q model.Query) string { return q.Color }),
+			newTermIndex("category", func(c *model.Car) string { return c.Category }, func(q model.Query) string { return q.Category }),
+		},
+		ranges: []*rangeIndex{
+			{
+				field:  "year",
+				value:  func(c *model.Car) int { return c.Year },
+				bounds: func(q model.Query) (*int, *int) { return q.YearMin, q.YearMax },
+			},
+			{
+				field:  "price",
+				value:  func(c *model.Car) int { return c.Price },
+				bounds: func(q model.Query) (*int, *int) { return q.PriceMin, q.PriceMax },
+			},
+			{
+				field:  "mileage",
+				value:  func(c *model.Car) int { return c.Mileage },
+				bounds: func(q model.Query) (*int, *int) { return nil, q.MileageMax },
+			},
+		},
+	}
+}
+
+func newTermIndex(field string, value func(*model.Car) string, query func(model.Query) string) *termIndex {
+	return &termIndex{
+		field: field,
+		value: value,
+		query: query,
+		keys:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (ix *carIndexes) add(key string, car *model.Car) {
+	for _, index := range ix.terms {
+		index.add(key, car)
+	}
+	for _, index := range ix.ranges {
+		index.add(key, car)
+	}
+}
+
+func (ix *carIndexes) remove(key string, car *model.Car) {
+	for _, index := range ix.terms {
+		index.remove(key, car)
+	}
+	for _, index := range ix.ranges {
+		index.remove(key, car)
+	}
+}
+
+// rebuild indexes db from scratch, which beats adding the cars one at a time
+// when loading a whole data set.
+func (ix *carIndexes) rebuild(db map[string]*model.Car) {
+	fresh := newCarIndexes()
+	for key, car := range db {
+		for _, index := range fresh.terms {
+			index.add(key, car)
+		}
+		for _, index := range fresh.ranges {
+			index.entries = append(index.entries, rangeEntry{value: index.value(car), key: key})
+		}
+	}
+	for _, index := range fresh.ranges {
+		sort.Slice(index.entries, func(i, j int) bool {
+			return index.entries[i].less(index.entries[j])
+		})
+	}
+	*ix = *fresh
+}
+
+// fold normalises a text value so that values equal under strings.EqualFold,
+// which Query.Matches uses, land in the same bucket.
+func fold(value string) string {
+	return strings.ToLower(strings.ToUpper(value))
+}
+
+func (index *termIndex) add(key string, car *model.Car) {
+	term := fold(index.value(car))
+	keys, ok := index.keys[term]
+	if !ok {
+		keys = make(map[string]struct{})
+		index.keys[term] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func (index *termIndex) remove(key string, car *model.Car) {
+	term := fold(index.value(car))
+	keys := index.keys[term]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(index.keys, term)
+	}
+}
+
+func (e rangeEntry) less(other rangeEntry) bool {
+	if e.value != other.value {
+		return e.value < other.value
+	}
+	return e.key < other.key
+}
+
+// search returns the position of entry, or where it would be inserted.
+func (index *rangeIndex) search(entry rangeEntry) int {
+	return sort.Search(len(index.entries), func(i int) bool {
+		return !index.entries[i].less(entry)
+	})
+}
+
+func (index *rangeIndex) add(key string, car *model.Car) {
+	entry := rangeEntry{value: index.value(car), key: key}
+	i := index.search(entry)
+	index.entries = append(index.entries, rangeEntry{})
+	copy(index.entries[i+1:], index.entries[i:])
+	index.entries[i] = entry
+}
+
+func (index *rangeIndex) remove(key string, car *model.Car) {
+	entry := rangeEntry{value: index.value(car), key: key}
+	i := index.search(entry)
+	if i < len(index.entries) && index.entries[i] == entry {
+		index.entries = append(index.entries[:i], index.entries[i+1:]...)
+	}
+}
+
+// span returns the entries with a value between lo and hi, either of which may
+// be unbounded.
+func (index *rangeIndex) span(lo, hi *int) []rangeEntry {
+	from, to := 0, len(index.entries)
+	if lo != nil {
+		from = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value >= *lo
+		})
+	}
+	if hi != nil {
+		to = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value > *hi
+		})
+	}
+	if to < from {
+		return nil
+	}
+	return index.entries[from:to]
+}
+
+// plan picks the index that narrows query down to the fewest cars, falling back
+// to a full scan, and returns a function that hands those cars to visit. The
+// cars still have to be checked against the whole query. The caller holds the
+// Repo's lock for as long as it uses the function.
+func (r *Repo) plan(query model.Query) (Plan, func(visit func(key string, car *model.Car))) {
+	best := Plan{Candidates: len(r.db)}
+	scan := func(visit func(string, *model.Car)) {
+		for key, car := range r.db {
+			visit(key, car)
+		}
+	}
+
+	for _, index := range r.indexes.terms {
+		value := index.query(query)
+		if value == "" {
+			continue
+		}
+		keys := index.keys[fold(value)]
+		if len(keys) < best.Candidates {
+			best = Plan{Index: index.field, Candidates: len(keys)}
+			scan = func(visit func(string, *model.Car)) {
+				for key := range keys {
+					visit(key, r.db[key])
+				}
+			}
+		}
+	}
+	for _, index := range r.indexes.ranges {
+		lo, hi := index.bounds(query)
+		if lo == nil && hi == nil {
+			continue
+		}
+		entries := index.span(lo, hi)
+		if len(entries) < best.Candidates {
+			best = Plan{Index: index.field, Candidates: len(entries)}
+			scan = func(visit func(string, *model.Car)) {
+				for _, entry := range entries {
+					visit(entry.key, r.db[entry.key])
+				}
+			}
+		}
+	}
+	return best, scan
+}
+
+// Explain reports how Find and Iterate would answer query right now.
+func (r *Repo) Explain(query model.Query) Plan {
+	r.RLock()
+	defer r.RUnlock()
+	plan, _ := r.plan(query)
+	return plan
+}
+
+// set stores car under key, or drops key when car is nil, and keeps the
+// indexes in step. The caller holds the write lock.
+func (r *Repo) set(key string, car *model.Car) {
+	if stored, ok := r.db[key]; ok {
+		r.indexes.remove(key, stored)
+	}
+	if car == nil {
+		delete(r.db, key)
+		return
+	}
+	r.db[key] = car
+	r.indexes.add(key, car)
+}
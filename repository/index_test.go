@@ -0,0 +1,185 @@
+package repository_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func carIDs(cars []*model.Car) []string {
+	ids := make([]string, len(cars))
+	for i, car := range cars {
+		ids[i] = car.ID
+	}
+	return ids
+}
+
+// fleet returns n cars whose fields cycle at different rates, so filters on
+// different fields select differently sized sets.
+func fleet(n int) []*model.Car {
+	makes := []string{"Honda", "Toyota", "Ford", "Tesla"}
+	colors := []string{"Red", "Blue", "Grey", "Black", "White", "Green", "Silver"}
+	categories := []string{"Sedan", "Coupe"}
+
+	cars := make([]*model.Car, n)
+	for i := range cars {
+		cars[i] = &model.Car{
+			ID:       fmt.Sprintf("car-%03d", i),
+			Make:     makes[i%len(makes)],
+			Model:    fmt.Sprintf("Model %d", i%10),
+			Color:    colors[i%len(colors)],
+			Category: categories[i%len(categories)],
+			Year:     2000 + i%25,
+			Price:    1000000 + i*10000,
+			Mileage:  (i * 7919) % 100000,
+		}
+	}
+	return cars
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestRepoIndexes(t *testing.T) {
+	t.Run("Planner picks the most selective index", func(t *testing.T) {
+		// Given
+		repo := repository.NewRepo()
+		for _, car := range fleet(100) {
+			assert.NoError(t, repo.Save(car.ID, car))
+		}
+
+		// Then
+		assert.Equal(t, repository.Plan{Candidates: 100}, repo.Explain(model.Query{}))
+		assert.Equal(t, repository.Plan{Index: "make", Candidates: 25}, repo.Explain(model.Query{Make: "honda"}))
+		assert.Equal(t, repository.Plan{Index: "color", Candidates: 15},
+			repo.Explain(model.Query{Make: "Honda", Color: "Red", Category: "Sedan"}))
+		assert.Equal(t, repository.Plan{Index: "year", Candidates: 4},
+			repo.Explain(model.Query{Make: "Honda", YearMin: intPtr(2024)}))
+		assert.Equal(t, repository.Plan{Index: "price", Candidates: 3},
+			repo.Explain(model.Query{YearMin: intPtr(2010), PriceMin: intPtr(1500000), PriceMax: intPtr(1520000)}))
+		assert.Equal(t, repository.Plan{Index: "model", Candidates: 0}, repo.Explain(model.Query{Model: "Civic"}))
+		// a filter that lets everything through is no better than a scan
+		assert.Equal(t, repository.Plan{Candidates: 100}, repo.Explain(model.Query{MileageMax: intPtr(100000)}))
+	})
+
+	t.Run("Results match a full scan", func(t *testing.T) {
+		// Given
+		repo := repository.NewRepo()
+		for _, car := range fleet(200) {
+			assert.NoError(t, repo.Save(car.ID, car))
+		}
+		all, err := repo.GetAll()
+		assert.NoError(t, err)
+
+		queries := []model.Query{
+			{Make: "TOYOTA"},
+			{Make: "Ford", Category: "coupe"},
+			{Color: "Silver", YearMax: intPtr(2010)},
+			{YearMin: intPtr(2005), YearMax: intPtr(2007), PriceMax: intPtr(2500000)},
+			{MileageMax: intPtr(5000), Sort: []model.SortField{{Field: "mileage"}}},
+			{PriceMin: intPtr(2900000), Sort: []model.SortField{{Field: "price", Desc: true}}},
+			{YearMin: intPtr(2020), YearMax: intPtr(2010)},
+			{Model: "model 3", Color: "Blue"},
+		}
+		for _, query := range queries {
+			// When
+			cars, err := repo.Find(query)
+
+			// Then
+			assert.NoError(t, err)
+			var want []*model.Car
+			for _, car := range all {
+				if query.Matches(car) {
+					want = append(want, car)
+				}
+			}
+			query.SortCars(want)
+			assert.Equal(t, carIDs(want), carIDs(cars), "query %+v", query)
+		}
+	})
+
+	t.Run("Indexes follow writes", func(t *testing.T) {
+		// Given
+		repo := repository.NewRepo()
+		assert.NoError(t, repo.Save("civic", &model.Car{ID: "civic", Make: "Honda", Year: 2019}))
+		assert.NoError(t, repo.Save("camry", &model.Car{ID: "camry", Make: "Toyota", Year: 2020}))
+		assert.NoError(t, repo.Save("corolla", &model.Car{ID: "corolla", Make: "Toyota", Year: 2021}))
+
+		// When
+		assert.NoError(t, repo.Update("civic", &model.Car{ID: "civic", Make: "Toyota", Year: 2022}))
+		assert.NoError(t, repo.Delete("camry"))
+		assert.NoError(t, repo.Save("corolla", &model.Car{ID: "corolla", Make: "Toyota", Year: 2018}))
+
+		// Then
+		cars, err := repo.Find(model.Query{Make: "toyota"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic", "corolla"}, carIDs(cars))
+
+		cars, err = repo.Find(model.Query{Make: "Honda"})
+		assert.NoError(t, err)
+		assert.Empty(t, cars)
+
+		cars, err = repo.Find(model.Query{YearMax: intPtr(2020)})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"corolla"}, carIDs(cars))
+		assert.Equal(t, repository.Plan{Index: "year", Candidates: 1}, repo.Explain(model.Query{YearMax: intPtr(2020)}))
+	})
+
+	t.Run("Committed transactions update the indexes", func(t *testing.T) {
+		// Given
+		repo := repository.NewRepo()
+		assert.NoError(t, repo.Save("civic", &model.Car{ID: "civic", Make: "Honda", Color: "Red"}))
+		assert.NoError(t, repo.Save("accord", &model.Car{ID: "accord", Make: "Honda", Color: "Blue"}))
+
+		tx, err := repo.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Update("civic", &model.Car{ID: "civic", Make: "Honda", Color: "Blue"}))
+		assert.NoError(t, tx.Delete("accord"))
+		assert.NoError(t, tx.Save("fit", &model.Car{ID: "fit", Make: "Honda", Color: "Red"}))
+
+		// reads inside the transaction see its writes over the indexed cars
+		cars, err := tx.Find(model.Query{Color: "blue"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, carIDs(cars))
+
+		// When
+		assert.NoError(t, tx.Commit())
+
+		// Then
+		cars, err = repo.Find(model.Query{Color: "blue"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, carIDs(cars))
+
+		cars, err = repo.Find(model.Query{Color: "red"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fit"}, carIDs(cars))
+	})
+
+	t.Run("FileStore rebuilds the indexes on restart", func(t *testing.T) {
+		// Given
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda", Year: 2019}))
+		assert.NoError(t, store.Compact())
+		assert.NoError(t, store.Save("camry", &model.Car{ID: "camry", Make: "Toyota", Year: 2020}))
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Make: "Honda", Year: 2021}))
+		assert.NoError(t, store.Close())
+
+		// When
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+
+		// Then
+		cars, err := reopened.Find(model.Query{Make: "honda"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, carIDs(cars))
+
+		cars, err = reopened.Find(model.Query{YearMin: intPtr(2020)})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"camry", "civic"}, carIDs(cars))
+	})
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// keyLocks is a fixed set of locks that keys hash onto. A decorator takes the
+// locks of the keys it writes to keep the writes to a car in order while
+// writes to other cars go ahead.
+type keyLocks [keyLockCount]sync.Mutex
+
+const keyLockCount = 64
+
+// lock takes the locks of keys and returns a func that releases them. Locks
+// are taken in a fixed order, so two callers never wait on each other.
+func (l *keyLocks) lock(keys ...string) func() {
+	taken := make([]bool, keyLockCount)
+	for _, key := range keys {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		taken[hash.Sum32()%keyLockCount] = true
+	}
+
+	for i := range taken {
+		if taken[i] {
+			l[i].Lock()
+		}
+	}
+	return func() {
+		for i := range taken {
+			if taken[i] {
+				l[i].Unlock()
+			}
+		}
+	}
+}
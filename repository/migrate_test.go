@@ -0,0 +1,84 @@
+package repository_test
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Orders migrations by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"0002_add_vin.up.sql":         {Data: []byte("ALTER TABLE things ADD COLUMN vin TEXT;")},
+			"0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id TEXT);")},
+			"0001_create_things.down.sql": {Data: []byte("DROP TABLE things;")},
+		}
+
+		migrations, err := repository.LoadMigrations(fsys)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, 1, migrations[0].Version)
+		assert.Equal(t, "create_things", migrations[0].Name)
+		assert.Equal(t, "DROP TABLE things;", migrations[0].Down)
+		assert.Equal(t, 2, migrations[1].Version)
+	})
+
+	t.Run("Rejects badly named files", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"create_things.up.sql": {Data: []byte("CREATE TABLE things (id TEXT);")},
+		}
+
+		_, err := repository.LoadMigrations(fsys)
+		assert.Error(t, err)
+	})
+
+	t.Run("Ships the bundled migrations", func(t *testing.T) {
+		migrations, err := repository.LoadMigrations(repository.Migrations())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, migrations)
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id TEXT);")},
+		"0001_create_things.down.sql": {Data: []byte("DROP TABLE things;")},
+		"0002_add_vin.up.sql":         {Data: []byte("ALTER TABLE things ADD COLUMN vin TEXT;")},
+		"0002_add_vin.down.sql":       {Data: []byte("ALTER TABLE things DROP COLUMN vin;")},
+	}
+
+	migrator, err := repository.NewMigrator(db, fsys)
+	assert.NoError(t, err)
+
+	t.Run("Up applies every migration once", func(t *testing.T) {
+		assert.NoError(t, migrator.Up())
+		assert.NoError(t, migrator.Up())
+
+		version, err := migrator.Version()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, version)
+
+		_, err = db.Exec(`INSERT INTO things (id, vin) VALUES ('a', 'b')`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("To rolls back down migrations", func(t *testing.T) {
+		assert.NoError(t, migrator.To(0))
+
+		version, err := migrator.Version()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, version)
+
+		_, err = db.Exec(`SELECT id FROM things`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,11 @@
+CREATE TABLE cars (
+    id       TEXT PRIMARY KEY,
+    make     TEXT NOT NULL DEFAULT '',
+    model    TEXT NOT NULL DEFAULT '',
+    package  TEXT NOT NULL DEFAULT '',
+    color    TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    year     INTEGER NOT NULL DEFAULT 0,
+    mileage  INTEGER NOT NULL DEFAULT 0,
+    price    INTEGER NOT NULL DEFAULT 0
+);
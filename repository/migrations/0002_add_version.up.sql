@@ -0,0 +1 @@
+ALTER TABLE cars ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE cars ADD COLUMN updated_by TEXT NOT NULL DEFAULT '';
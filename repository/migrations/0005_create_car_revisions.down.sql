@@ -0,0 +1 @@
+DROP TABLE car_revisions;
@@ -0,0 +1,8 @@
+CREATE TABLE car_revisions (
+    seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+    car_id TEXT NOT NULL,
+    op     TEXT NOT NULL,
+    at     TIMESTAMP NOT NULL,
+    car    TEXT
+);
+CREATE INDEX car_revisions_car_id ON car_revisions (car_id);
@@ -0,0 +1,3 @@
+DROP INDEX car_revisions_car_id_number;
+CREATE INDEX car_revisions_car_id ON car_revisions (car_id);
+ALTER TABLE car_revisions DROP COLUMN number;
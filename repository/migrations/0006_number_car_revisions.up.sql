@@ -0,0 +1,7 @@
+ALTER TABLE car_revisions ADD COLUMN number INTEGER NOT NULL DEFAULT 0;
+UPDATE car_revisions SET number = (
+    SELECT COUNT(*) FROM car_revisions AS earlier
+    WHERE earlier.car_id = car_revisions.car_id AND earlier.seq <= car_revisions.seq
+);
+DROP INDEX car_revisions_car_id;
+CREATE UNIQUE INDEX car_revisions_car_id_number ON car_revisions (car_id, number);
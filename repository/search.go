@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+	"log"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/search"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// Searcher is implemented by storage that can find cars by free text.
+type Searcher interface {
+	// Search returns the cars matching text that also match query, the most
+	// relevant first. limit caps the hits unless it is 0.
+	Search(text string, query model.Query, limit int) ([]model.SearchHit, error)
+}
+
+// SearchStore is a Storage that keeps a full-text index of the live cars in the
+// underlying storage up to date with every write it passes on. Soft deleted
+// cars leave the index and come back when restored. Searches are answered from
+// the index alone, which keeps a copy of every car it holds.
+type SearchStore struct {
+	Storage
+	index *search.Index
+
+	// a write takes the lock of its key over both the write and the
+	// indexing, so each car is indexed in the order it was written while
+	// writes to other cars go ahead
+	locks keyLocks
+}
+
+// NewSearchStore indexes the cars already in storage and the writes to it.
+func NewSearchStore(storage Storage) (*SearchStore, error) {
+	s := &SearchStore{
+		Storage: storage,
+		index:   search.NewIndex(),
+	}
+
+	err := storage.Iterate(model.Query{}, func(car *model.Car) error {
+		s.index.Add(car.ID, car)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unwrap returns the underlying storage.
+func (s *SearchStore) Unwrap() Storage {
+	return s.Storage
+}
+
+func (s *SearchStore) Save(key string, object *model.Car) error {
+	defer s.locks.lock(key)()
+
+	if err := s.Storage.Save(key, object); err != nil {
+		return err
+	}
+	s.indexCar(key, object)
+	return nil
+}
+
+func (s *SearchStore) Update(key string, object *model.Car) error {
+	defer s.locks.lock(key)()
+
+	if err := s.Storage.Update(key, object); err != nil {
+		return err
+	}
+	s.indexCar(key, object)
+	return nil
+}
+
+func (s *SearchStore) Delete(key string) error {
+	defer s.locks.lock(key)()
+
+	if err := s.Storage.Delete(key); err != nil {
+		return err
+	}
+	s.index.Remove(key)
+	return nil
+}
+
+// Begin starts a transaction on the underlying storage whose writes are
+// indexed once it commits.
+func (s *SearchStore) Begin() (Tx, error) {
+	tx, err := s.Storage.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return watchTx(tx, func(writes []txWrite) {
+		s.reindex(writtenKeys(writes))
+	}), nil
+}
+
+func (s *SearchStore) Search(text string, query model.Query, limit int) ([]model.SearchHit, error) {
+	hits := []model.SearchHit{}
+	for _, result := range s.index.Search(text, query.Matches, limit) {
+		hits = append(hits, model.SearchHit{Car: result.Car, Score: result.Score})
+	}
+	return hits, nil
+}
+
+// indexCar indexes the car written under key, unless it is soft deleted.
+func (s *SearchStore) indexCar(key string, car *model.Car) {
+	if car.Deleted() {
+		s.index.Remove(key)
+		return
+	}
+	s.index.Add(key, car)
+}
+
+// reindex indexes the cars under keys as the underlying storage holds them.
+// A committed transaction indexes its keys this way rather than with the cars
+// it wrote: it only takes their locks after committing, so a write made since
+// may already be indexed.
+func (s *SearchStore) reindex(keys []string) {
+	defer s.locks.lock(keys...)()
+
+	for _, key := range keys {
+		car, err := s.Storage.Get(key)
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			s.index.Remove(key)
+		case err != nil:
+			log.Printf("error while indexing car %s. err: %v\n", key, err)
+		default:
+			s.indexCar(key, car)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func hitIDs(hits []model.SearchHit) []string {
+	out := []string{}
+	for _, hit := range hits {
+		out = append(out, hit.Car.ID)
+	}
+	return out
+}
+
+// racingStorage calls race right after each of its transactions commits.
+type racingStorage struct {
+	repository.Storage
+	race func()
+}
+
+func (s *racingStorage) Begin() (repository.Tx, error) {
+	tx, err := s.Storage.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &racingTx{Tx: tx, race: s.race}, nil
+}
+
+type racingTx struct {
+	repository.Tx
+	race func()
+}
+
+func (tx *racingTx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	tx.race()
+	return nil
+}
+
+func TestSearchStore(t *testing.T) {
+	// cars stored before the index was built are found too
+	repo := repository.NewRepo()
+	assert.NoError(t, repo.Save("civic", &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Color: "Red", Year: 2019}))
+
+	store, err := repository.NewSearchStore(repo)
+	assert.NoError(t, err)
+
+	hits, err := store.Search("civic", model.Query{}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"civic"}, hitIDs(hits))
+
+	t.Run("Follows writes", func(t *testing.T) {
+		assert.NoError(t, store.Save("accord", &model.Car{ID: "accord", Make: "Honda", Model: "Accord", Color: "Red", Year: 2021}))
+		assert.NoError(t, store.Update("civic", &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Color: "Blue", Year: 2019}))
+
+		hits, err := store.Search("red", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"accord"}, hitIDs(hits))
+
+		assert.NoError(t, store.Delete("accord"))
+		hits, err = store.Search("accord", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("Leaves out soft deleted cars until restored", func(t *testing.T) {
+		deletedAt := time.Now()
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2019, DeletedAt: &deletedAt}
+		assert.NoError(t, store.Update(car.ID, car))
+
+		hits, err := store.Search("civic", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, hits)
+
+		car.DeletedAt = nil
+		assert.NoError(t, store.Update(car.ID, car))
+		hits, err = store.Search("civic", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, hitIDs(hits))
+	})
+
+	t.Run("Indexes transactions once they commit", func(t *testing.T) {
+		tx, err := store.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Save("soul", &model.Car{ID: "soul", Make: "Kia", Model: "Soul"}))
+
+		hits, err := store.Search("soul", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, hits)
+
+		assert.NoError(t, tx.Commit())
+		hits, err = store.Search("soul", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"soul"}, hitIDs(hits))
+
+		tx, err = store.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Delete("soul"))
+		assert.NoError(t, tx.Rollback())
+		hits, err = store.Search("soul", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"soul"}, hitIDs(hits))
+	})
+
+	t.Run("Filters and limits the hits", func(t *testing.T) {
+		assert.NoError(t, store.Save("fit", &model.Car{ID: "fit", Make: "Honda", Model: "Fit", Year: 2015}))
+
+		yearMin := 2018
+		hits, err := store.Search("honda", model.Query{YearMin: &yearMin}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, hitIDs(hits))
+
+		hits, err = store.Search("honda", model.Query{}, 1)
+		assert.NoError(t, err)
+		assert.Len(t, hits, 1)
+	})
+
+	t.Run("Answers from the index alone", func(t *testing.T) {
+		backend := &countingStorage{Storage: repository.NewRepo()}
+		store, err := repository.NewSearchStore(backend)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}))
+
+		hits, err := store.Search("civic", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"civic"}, hitIDs(hits))
+		assert.Zero(t, backend.gets)
+
+		// the hits are copies
+		hits[0].Car.Model = "Accord"
+		hits, err = store.Search("civic", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "Civic", hits[0].Car.Model)
+	})
+
+	t.Run("A transaction does not index over a later write", func(t *testing.T) {
+		backend := &racingStorage{Storage: repository.NewRepo()}
+		store, err := repository.NewSearchStore(backend)
+		assert.NoError(t, err)
+		// the write gets in after the transaction commits and before it is indexed
+		backend.race = func() {
+			assert.NoError(t, store.Save("rio", &model.Car{ID: "rio", Make: "Kia", Model: "Rio", Color: "Yellow"}))
+		}
+
+		err = repository.WithTx(store, func(tx repository.Tx) error {
+			return tx.Save("rio", &model.Car{ID: "rio", Make: "Kia", Model: "Rio", Color: "Green"})
+		})
+		assert.NoError(t, err)
+
+		hits, err := store.Search("green", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, hits)
+		hits, err = store.Search("yellow", model.Query{}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"rio"}, hitIDs(hits))
+	})
+}
+
+func TestAs(t *testing.T) {
+	history := repository.NewHistoryStore(repository.NewRepo(), time.Now)
+	store, err := repository.NewSearchStore(history)
+	assert.NoError(t, err)
+
+	found, ok := repository.As[repository.History](store)
+	assert.True(t, ok)
+	assert.Same(t, history, found)
+
+	_, ok = repository.As[repository.Searcher](history)
+	assert.False(t, ok)
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+const (
+	// DefaultShards is the number of partitions NewShardedRepo uses when asked
+	// for none.
+	DefaultShards = 16
+	// ringReplicas is how many points every shard gets on the hash ring. More
+	// points spread the keys more evenly.
+	ringReplicas = 128
+)
+
+// ShardedRepo keeps cars in memory like Repo, but spreads the keys over
+// several Repos with their own locks, so writers to different shards do not
+// wait on each other. Keys are placed by consistent hashing, which keeps most
+// of them in place when the number of shards changes.
+//
+// Reads that span keys, such as GetAll and Find, visit the shards one at a
+// time and merge what they find. Transactions may touch any shards and commit
+// atomically across all of them.
+type ShardedRepo struct {
+	shards []*Repo
+	ring   hashRing
+}
+
+// NewShardedRepo returns an empty store with n shards, or DefaultShards when n
+// is not positive.
+func NewShardedRepo(n int) *ShardedRepo {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	shards := make([]*Repo, n)
+	for i := range shards {
+		shards[i] = NewRepo()
+	}
+	return &ShardedRepo{
+		shards: shards,
+		ring:   newHashRing(n, ringReplicas),
+	}
+}
+
+// Shard reports which of the shards, numbered from 0, holds key.
+func (r *ShardedRepo) Shard(key string) int {
+	return r.ring.shard(key)
+}
+
+func (r *ShardedRepo) shard(key string) *Repo {
+	return r.shards[r.ring.shard(key)]
+}
+
+func (r *ShardedRepo) Save(key string, object *model.Car) error {
+	return r.shard(key).Save(key, object)
+}
+
+func (r *ShardedRepo) Get(key string) (*model.Car, error) {
+	return r.shard(key).Get(key)
+}
+
+func (r *ShardedRepo) GetAll() ([]*model.Car, error) {
+	var cars []*model.Car
+	for _, shard := range r.shards {
+		found, err := shard.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		cars = append(cars, found...)
+	}
+	sort.Slice(cars, func(i, j int) bool {
+		return cars[i].ID < cars[j].ID
+	})
+	return cars, nil
+}
+
+func (r *ShardedRepo) Find(query model.Query) ([]*model.Car, error) {
+	cars := r.matching(query)
+	for i, car := range cars {
+		cars[i] = car.Clone()
+	}
+	return cars, nil
+}
+
+func (r *ShardedRepo) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	return iterate(r.matching(query), fn)
+}
+
+// matching merges the matching cars of every shard, sorted. They are shared
+// with the shards and must be cloned before they are handed out.
+func (r *ShardedRepo) matching(query model.Query) []*model.Car {
+	var cars []*model.Car
+	for _, shard := range r.shards {
+		cars = append(cars, shard.matching(query)...)
+	}
+	query.SortCars(cars)
+	return cars
+}
+
+func (r *ShardedRepo) Delete(key string) error {
+	return r.shard(key).Delete(key)
+}
+
+func (r *ShardedRepo) Update(key string, object *model.Car) error {
+	return r.shard(key).Update(key, object)
+}
+
+// Begin starts a transaction with a part on every shard.
+func (r *ShardedRepo) Begin() (Tx, error) {
+	parts := make([]*repoTx, len(r.shards))
+	for i, shard := range r.shards {
+		parts[i] = shard.begin()
+	}
+	return &shardedTx{store: r, parts: parts}, nil
+}
+
+// shardedTx runs a repoTx on every shard and commits them together. The parts
+// keep the state of the transaction, including whether it is done.
+type shardedTx struct {
+	store *ShardedRepo
+	parts []*repoTx // in shard order
+}
+
+func (tx *shardedTx) part(key string) *repoTx {
+	return tx.parts[tx.store.ring.shard(key)]
+}
+
+func (tx *shardedTx) Save(key string, object *model.Car) error {
+	return tx.part(key).Save(key, object)
+}
+
+func (tx *shardedTx) Get(key string) (*model.Car, error) {
+	return tx.part(key).Get(key)
+}
+
+func (tx *shardedTx) GetAll() ([]*model.Car, error) {
+	return tx.Find(model.Query{IncludeDeleted: true})
+}
+
+func (tx *shardedTx) Find(query model.Query) ([]*model.Car, error) {
+	cars, err := tx.matching(query)
+	if err != nil {
+		return nil, err
+	}
+	for i, car := range cars {
+		cars[i] = car.Clone()
+	}
+	return cars, nil
+}
+
+func (tx *shardedTx) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	cars, err := tx.matching(query)
+	if err != nil {
+		return err
+	}
+	return iterate(cars, fn)
+}
+
+func (tx *shardedTx) matching(query model.Query) ([]*model.Car, error) {
+	var cars []*model.Car
+	for _, part := range tx.parts {
+		found, err := part.matching(query)
+		if err != nil {
+			return nil, err
+		}
+		cars = append(cars, found...)
+	}
+	query.SortCars(cars)
+	return cars, nil
+}
+
+func (tx *shardedTx) Delete(key string) error {
+	return tx.part(key).Delete(key)
+}
+
+func (tx *shardedTx) Update(key string, object *model.Car) error {
+	return tx.part(key).Update(key, object)
+}
+
+// Commit locks the shards the transaction wrote to, always in shard order so
+// concurrent commits cannot deadlock, and applies the writes only once none of
+// the shards reports a conflict.
+func (tx *shardedTx) Commit() error {
+	if err := tx.finish(); err != nil {
+		return err
+	}
+	defer tx.unlockParts()
+
+	var written []*repoTx
+	for _, part := range tx.parts {
+		if len(part.keys) > 0 {
+			written = append(written, part)
+		}
+	}
+	for _, part := range written {
+		part.repo.Lock()
+		defer part.repo.Unlock()
+	}
+	for _, part := range written {
+		if err := part.conflict(); err != nil {
+			return err
+		}
+	}
+	for _, part := range written {
+		part.apply()
+	}
+	return nil
+}
+
+func (tx *shardedTx) Rollback() error {
+	if err := tx.finish(); err != nil {
+		return err
+	}
+	tx.unlockParts()
+	return nil
+}
+
+// finish locks every part and marks it done, or returns utils.ErrTxDone with
+// nothing locked when the transaction already finished.
+func (tx *shardedTx) finish() error {
+	for _, part := range tx.parts {
+		part.mu.Lock()
+	}
+	if tx.parts[0].done {
+		tx.unlockParts()
+		return utils.ErrTxDone
+	}
+	for _, part := range tx.parts {
+		part.done = true
+	}
+	return nil
+}
+
+func (tx *shardedTx) unlockParts() {
+	for _, part := range tx.parts {
+		part.mu.Unlock()
+	}
+}
+
+// hashRing places keys on shards by consistent hashing: every shard owns
+// several points on a ring of hashes, and a key belongs to the shard owning
+// the first point at or after the key's hash.
+type hashRing struct {
+	points []ringPoint // by hash
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+func newHashRing(shards, replicas int) hashRing {
+	points := make([]ringPoint, 0, shards*replicas)
+	for shard := 0; shard < shards; shard++ {
+		for replica := 0; replica < replicas; replica++ {
+			points = append(points, ringPoint{
+				hash:  ringHash(fmt.Sprintf("shard-%d#%d", shard, replica)),
+				shard: shard,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].hash < points[j].hash
+	})
+	return hashRing{points: points}
+}
+
+func (ring hashRing) shard(key string) int {
+	hash := ringHash(key)
+	i := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i].hash >= hash
+	})
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.points[i].shard
+}
+
+// ringHash is FNV-1a followed by a finalizer that spreads keys differing only
+// in their last characters, such as sequential IDs, around the whole ring.
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
@@ -0,0 +1,109 @@
+package repository_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedRepo(t *testing.T) {
+	t.Run("Keys spread evenly over the shards", func(t *testing.T) {
+		repo := repository.NewShardedRepo(8)
+
+		counts := make([]int, 8)
+		for i := 0; i < 8000; i++ {
+			counts[repo.Shard(fmt.Sprintf("car-%d", i))]++
+		}
+		for shard, count := range counts {
+			assert.InDelta(t, 1000, count, 300, "shard %d", shard)
+		}
+	})
+
+	t.Run("Adding a shard moves few keys", func(t *testing.T) {
+		before := repository.NewShardedRepo(8)
+		after := repository.NewShardedRepo(9)
+
+		moved := 0
+		for i := 0; i < 9000; i++ {
+			key := fmt.Sprintf("car-%d", i)
+			if before.Shard(key) != after.Shard(key) {
+				moved++
+			}
+		}
+		// ideally a ninth of the keys move, to the new shard
+		assert.Less(t, moved, 9000/9*2)
+	})
+
+	t.Run("Defaults the number of shards", func(t *testing.T) {
+		repo := repository.NewShardedRepo(0)
+
+		seen := make(map[int]bool)
+		for i := 0; i < 1000; i++ {
+			seen[repo.Shard(fmt.Sprintf("car-%d", i))] = true
+		}
+		assert.Len(t, seen, repository.DefaultShards)
+	})
+
+	t.Run("Transactions commit across shards at once", func(t *testing.T) {
+		repo := repository.NewShardedRepo(4)
+		var keys []string
+		for i := 0; len(keys) < 2; i++ {
+			key := fmt.Sprintf("car-%d", i)
+			if len(keys) == 0 || repo.Shard(key) != repo.Shard(keys[0]) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			assert.NoError(t, repo.Save(key, &model.Car{ID: key, Make: "Honda"}))
+		}
+
+		tx, err := repo.Begin()
+		assert.NoError(t, err)
+		for _, key := range keys {
+			assert.NoError(t, tx.Update(key, &model.Car{ID: key, Make: "Toyota"}))
+		}
+
+		// a write to the second shard behind the transaction's back sinks the
+		// writes to the first one too
+		assert.NoError(t, repo.Update(keys[1], &model.Car{ID: keys[1], Make: "Ford"}))
+		assert.ErrorIs(t, tx.Commit(), utils.ErrConflict)
+		assert.ErrorIs(t, tx.Rollback(), utils.ErrTxDone)
+
+		object, err := repo.Get(keys[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "Honda", object.Make)
+	})
+
+	t.Run("Concurrent transactions do not deadlock", func(t *testing.T) {
+		repo := repository.NewShardedRepo(4)
+
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					_ = repository.WithTx(repo, func(tx repository.Tx) error {
+						for j := 0; j < 4; j++ {
+							key := fmt.Sprintf("car-%d", (worker+i+j)%10)
+							if err := tx.Save(key, &model.Car{ID: key}); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+				}
+			}(worker)
+		}
+		wg.Wait()
+
+		cars, err := repo.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 10)
+	})
+}
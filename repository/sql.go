@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+	"modernc.org/sqlite"
+)
+
+const carColumns = `id, make, model, package, color, category, year, mileage, price, version, updated_by, deleted_at`
+
+// SQLStore is a Storage backed by a cars table in a SQL database. Queries are
+// written for SQLite through the modernc.org/sqlite driver, with which the
+// package registers foldCollation.
+type SQLStore struct {
+	sqlCars
+	conn *sql.DB
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqlCars runs the car statements against a database or inside a transaction.
+type sqlCars struct {
+	db querier
+}
+
+// foldCollation compares text the way Query.Matches does. SQLite's own NOCASE
+// only folds ASCII, so "škoda" would not match "Škoda" as it does in memory.
+const foldCollation = "FOLD"
+
+func init() {
+	sqlite.MustRegisterCollationUtf8(foldCollation, func(left, right string) int {
+		return strings.Compare(fold(left), fold(right))
+	})
+}
+
+// SQLiteDSN adds to a SQLite data source name the settings the store relies on
+// when it is used from several goroutines: writers wait up to five seconds for
+// each other instead of failing with SQLITE_BUSY, readers do not block writers,
+// and a transaction takes the write lock when it begins, so two transactions
+// that both read before they write cannot deadlock.
+func SQLiteDSN(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+}
+
+// NewSQLStore migrates db to the latest schema and returns a store over it.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	migrator, err := NewMigrator(db, Migrations())
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.Up(); err != nil {
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+
+	return &SQLStore{
+		sqlCars: sqlCars{db: db},
+		conn:    db,
+	}, nil
+}
+
+// Begin starts a database transaction.
+func (s *SQLStore) Begin() (Tx, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{
+		sqlCars: sqlCars{db: tx},
+		tx:      tx,
+	}, nil
+}
+
+func (s *SQLStore) readHistory(key string, fn func(entry historyEntry) error) error {
+	rows, err := s.conn.Query(`SELECT number, op, at, car FROM car_revisions WHERE car_id = ? ORDER BY number`, key)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := historyEntry{Key: key}
+		var car sql.NullString
+		if err := rows.Scan(&entry.Number, &entry.Op, &entry.At, &car); err != nil {
+			return err
+		}
+		if car.Valid {
+			if err := json.Unmarshal([]byte(car.String), &entry.Car); err != nil {
+				return fmt.Errorf("decoding revision of car %s: %w", entry.Key, err)
+			}
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type sqlTx struct {
+	sqlCars
+	tx *sql.Tx
+}
+
+// appendHistory stores a revision in the car_revisions table, numbered after
+// the last one of its car. The car is kept as JSON, so revisions outlast
+// changes to the columns of the cars table.
+func (tx *sqlTx) appendHistory(entry historyEntry) error {
+	var car sql.NullString
+	if entry.Car != nil {
+		data, err := json.Marshal(entry.Car)
+		if err != nil {
+			return err
+		}
+		car = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := tx.tx.Exec(`INSERT INTO car_revisions (car_id, number, op, at, car)
+		SELECT ?, COALESCE(MAX(number), 0) + 1, ?, ?, ? FROM car_revisions WHERE car_id = ?`,
+		entry.Key, entry.Op, entry.At, car, entry.Key)
+	return err
+}
+
+func (tx *sqlTx) Commit() error {
+	return txDone(tx.tx.Commit())
+}
+
+func (tx *sqlTx) Rollback() error {
+	return txDone(tx.tx.Rollback())
+}
+
+// txDone reports sql.ErrTxDone as utils.ErrTxDone.
+func txDone(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return utils.ErrTxDone
+	}
+	return err
+}
+
+func (s *sqlCars) Save(key string, object *model.Car) error {
+	const query = `INSERT INTO cars (` + carColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			make = excluded.make,
+			model = excluded.model,
+			package = excluded.package,
+			color = excluded.color,
+			category = excluded.category,
+			year = excluded.year,
+			mileage = excluded.mileage,
+			price = excluded.price,
+			version = cars.version + 1,
+			updated_by = excluded.updated_by,
+			deleted_at = excluded.deleted_at
+		RETURNING version`
+
+	return s.db.QueryRow(query, key, object.Make, object.Model, object.Package, object.Color,
+		object.Category, object.Year, object.Mileage, object.Price, object.UpdatedBy,
+		nullTime(object.DeletedAt)).Scan(&object.Version)
+}
+
+func (s *sqlCars) Get(key string) (*model.Car, error) {
+	row := s.db.QueryRow(`SELECT `+carColumns+` FROM cars WHERE id = ?`, key)
+
+	car, err := scanCar(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrNotFound
+	}
+	return car, err
+}
+
+func (s *sqlCars) GetAll() ([]*model.Car, error) {
+	return s.Find(model.Query{IncludeDeleted: true})
+}
+
+func (s *sqlCars) Find(query model.Query) ([]*model.Car, error) {
+	var cars []*model.Car
+	err := s.Iterate(query, func(car *model.Car) error {
+		cars = append(cars, car)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+// Iterate scans the cars off the result set as fn asks for them, so only one
+// is held in memory at a time.
+func (s *sqlCars) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	where, args := whereClause(query)
+	rows, err := s.db.Query(`SELECT `+carColumns+` FROM cars`+where+orderByClause(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(car); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlCars) Delete(key string) error {
+	result, err := s.db.Exec(`DELETE FROM cars WHERE id = ?`, key)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (s *sqlCars) Update(key string, object *model.Car) error {
+	const query = `UPDATE cars SET
+			make = ?, model = ?, package = ?, color = ?, category = ?, year = ?, mileage = ?, price = ?,
+			updated_by = ?, deleted_at = ?, version = version + 1
+		WHERE id = ? AND (? = 0 OR version = ?)
+		RETURNING version`
+
+	var version int64
+	err := s.db.QueryRow(query, object.Make, object.Model, object.Package, object.Color,
+		object.Category, object.Year, object.Mileage, object.Price, object.UpdatedBy,
+		nullTime(object.DeletedAt), key, object.Version, object.Version).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		// either the car is gone or its version moved on
+		stored, err := s.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(stored, object); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: car changed during the update", utils.ErrPreconditionFailed)
+	}
+	if err != nil {
+		return err
+	}
+
+	object.Version = version
+	return nil
+}
+
+// expectAffected turns a statement that matched no rows into utils.ErrNotFound.
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// whereClause translates the filters of a query into a WHERE clause and its arguments.
+func whereClause(query model.Query) (string, []any) {
+	var conditions []string
+	var args []any
+
+	equal := func(column, value string) {
+		if value != "" {
+			conditions = append(conditions, column+" = ? COLLATE "+foldCollation)
+			args = append(args, value)
+		}
+	}
+	bound := func(column, op string, value *int) {
+		if value != nil {
+			conditions = append(conditions, column+" "+op+" ?")
+			args = append(args, *value)
+		}
+	}
+
+	if !query.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	equal("make", query.Make)
+	equal("model", query.Model)
+	equal("color", query.Color)
+	equal("category", query.Category)
+	bound("year", ">=", query.YearMin)
+	bound("year", "<=", query.YearMax)
+	bound("price", ">=", query.PriceMin)
+	bound("price", "<=", query.PriceMax)
+	bound("mileage", "<=", query.MileageMax)
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderByClause translates the sort fields of a query into an ORDER BY clause.
+// Only sortable fields are used, so they are safe to use as column names.
+func orderByClause(query model.Query) string {
+	var terms []string
+	for _, field := range query.Sort {
+		if !model.IsSortable(field.Field) {
+			continue
+		}
+		term := field.Field
+		if field.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	terms = append(terms, "id")
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCar(row scanner) (*model.Car, error) {
+	var car model.Car
+	var deletedAt sql.NullTime
+	err := row.Scan(&car.ID, &car.Make, &car.Model, &car.Package, &car.Color,
+		&car.Category, &car.Year, &car.Mileage, &car.Price, &car.Version, &car.UpdatedBy, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		car.DeletedAt = &deletedAt.Time
+	}
+	return &car, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -0,0 +1,155 @@
+package repository_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func newSQLStore(t *testing.T) *repository.SQLStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	// every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := repository.NewSQLStore(db)
+	assert.NoError(t, err)
+	return store
+}
+
+func TestSQLStore(t *testing.T) {
+	t.Run("Save and get", func(t *testing.T) {
+		store := newSQLStore(t)
+
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Package: "Sport", Color: "Red",
+			Category: "Sedan", Year: 2019, Mileage: 12000, Price: 2000000}
+		assert.NoError(t, store.Save(car.ID, car))
+
+		object, err := store.Get(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, car, object)
+	})
+
+	t.Run("Update and delete", func(t *testing.T) {
+		store := newSQLStore(t)
+
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic"}
+		assert.NoError(t, store.Save(car.ID, car))
+
+		accord := &model.Car{ID: "civic", Make: "Honda", Model: "Accord"}
+		assert.NoError(t, store.Update(car.ID, accord))
+
+		object, err := store.Get(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Accord", object.Model)
+
+		assert.ErrorIs(t, store.Update("missing", accord), utils.ErrNotFound)
+
+		assert.NoError(t, store.Delete(car.ID))
+		object, err = store.Get(car.ID)
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+		assert.Nil(t, object)
+	})
+
+	t.Run("Get all", func(t *testing.T) {
+		store := newSQLStore(t)
+
+		for _, id := range []string{"b", "a", "c"} {
+			assert.NoError(t, store.Save(id, &model.Car{ID: id, Make: "Honda"}))
+		}
+
+		cars, err := store.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 3)
+		assert.Equal(t, "a", cars[0].ID)
+	})
+}
+
+func TestSQLStoreFind(t *testing.T) {
+	store := newSQLStore(t)
+
+	cars := []*model.Car{
+		{ID: "a", Make: "Honda", Model: "Civic", Color: "Red", Year: 2019, Mileage: 12000, Price: 2000000},
+		{ID: "b", Make: "Honda", Model: "Accord", Color: "Blue", Year: 2021, Mileage: 100, Price: 3000000},
+		{ID: "c", Make: "Toyota", Model: "Camry", Color: "Red", Year: 2021, Mileage: 500, Price: 2800000},
+	}
+	for _, car := range cars {
+		assert.NoError(t, store.Save(car.ID, car))
+	}
+
+	t.Run("Filters ignore case", func(t *testing.T) {
+		found, err := store.Find(model.Query{Make: "honda"})
+		assert.NoError(t, err)
+		assert.Equal(t, cars[:2], found)
+	})
+
+	t.Run("Ranges and sort", func(t *testing.T) {
+		yearMin, mileageMax := 2020, 1000
+		found, err := store.Find(model.Query{
+			YearMin:    &yearMin,
+			MileageMax: &mileageMax,
+			Sort:       []model.SortField{{Field: "price"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []*model.Car{cars[2], cars[1]}, found)
+	})
+}
+
+func TestSQLStoreConcurrentTransactions(t *testing.T) {
+	db, err := sql.Open("sqlite", repository.SQLiteDSN(filepath.Join(t.TempDir(), "cars.db")))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	store, err := repository.NewSQLStore(db)
+	assert.NoError(t, err)
+
+	counter := &model.Car{ID: "counter", Make: "Honda", Model: "Civic"}
+	assert.NoError(t, store.Save(counter.ID, counter))
+
+	const workers = 20
+	const rounds = 20
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				// every transaction reads before it writes, which deadlocks
+				// transactions that only take the write lock on their first write
+				err := repository.WithTx(store, func(tx repository.Tx) error {
+					car, err := tx.Get(counter.ID)
+					if err != nil {
+						return err
+					}
+					car.Price++
+					if err := tx.Update(car.ID, car); err != nil {
+						return err
+					}
+					id := fmt.Sprintf("car-%d-%d", w, i)
+					return tx.Save(id, &model.Car{ID: id, Make: "Honda", Model: "Civic"})
+				})
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	object, err := store.Get(counter.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, workers*rounds, object.Price)
+
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, cars, workers*rounds+1)
+}
@@ -1,41 +1,92 @@
 package repository
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
 )
 
+// Storage persists cars by key. Get, Update and Delete return utils.ErrNotFound
+// when the key does not exist. GetAll orders cars by ID and Find by the query's
+// sort fields, then ID. Cars passed in and handed out are never shared with the
+// storage, so callers are free to modify them while other goroutines use it.
+//
+// Every stored car carries a version that starts at 1 and grows with each
+// write; Save and Update report the new version in object.Version. Update
+// treats a non-zero object.Version as the version the caller expects to
+// replace and returns utils.ErrPreconditionFailed when it is stale.
+//
+// Soft deleting is up to the caller, which sets DeletedAt through Update. Get
+// and GetAll still return soft deleted cars, Find only when the query says so,
+// and Delete removes a car for good.
+//
+// Begin starts a transaction that makes several writes atomic; see WithTx.
 type Storage interface {
-	Save(key string, object *model.Car) error
-	Get(key string) (*model.Car, error)
-	GetAll() ([]*model.Car, error)
-	Delete(key string) error
-	Update(key string, value *model.Car) error
+	Store
+	Begin() (Tx, error)
 }
 
+// As looks for a T among storage and the storages it decorates, following
+// their Unwrap methods, so optional interfaces such as History and Searcher
+// stay reachable however the decorators are stacked.
+func As[T any](storage Storage) (T, bool) {
+	for storage != nil {
+		if found, ok := storage.(T); ok {
+			return found, true
+		}
+		decorator, ok := storage.(interface{ Unwrap() Storage })
+		if !ok {
+			break
+		}
+		storage = decorator.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Repo keeps cars in memory. Alongside the cars it maintains secondary indexes
+// on the fields Query filters by, and Find and Iterate read the cars through
+// whichever index narrows a query down the most; see Explain.
+//
+// Writes store a copy of the car they are given and reads hand out copies, so
+// no caller ever holds a car the Repo keeps. The isolation case of storagetest
+// checks this, and a Repo that kept the callers' pointers would fail it.
 type Repo struct {
-	db map[string]*model.Car
+	db      map[string]*model.Car
+	indexes *carIndexes
 	sync.RWMutex
 }
 
 func NewRepo() *Repo {
 	return &Repo{
-		db: make(map[string]*model.Car),
+		db:      make(map[string]*model.Car),
+		indexes: newCarIndexes(),
 	}
 }
 
 func (r *Repo) Save(key string, object *model.Car) error {
 	r.Lock()
 	defer r.Unlock()
-	r.db[key] = object
+	object.Version = 1
+	if stored, ok := r.db[key]; ok {
+		object.Version = stored.Version + 1
+	}
+	r.set(key, object.Clone())
 	return nil
 }
 
 func (r *Repo) Get(key string) (*model.Car, error) {
 	r.RLock()
 	defer r.RUnlock()
-	return r.db[key], nil
+	car, ok := r.db[key]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	return car.Clone(), nil
 }
 
 func (r *Repo) GetAll() ([]*model.Car, error) {
@@ -43,27 +94,96 @@ func (r *Repo) GetAll() ([]*model.Car, error) {
 	defer r.RUnlock()
 	var cars []*model.Car
 	for _, car := range r.db {
-		cars = append(cars, car)
+		cars = append(cars, car.Clone())
 	}
+	sort.Slice(cars, func(i, j int) bool {
+		return cars[i].ID < cars[j].ID
+	})
 	return cars, nil
 }
 
+func (r *Repo) Find(query model.Query) ([]*model.Car, error) {
+	cars := r.matching(query)
+	for i, car := range cars {
+		cars[i] = car.Clone()
+	}
+	return cars, nil
+}
+
+func (r *Repo) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	return iterate(r.matching(query), fn)
+}
+
+// matching returns the stored cars that match query, sorted. They are shared
+// with the Repo and must be cloned before they are handed out.
+func (r *Repo) matching(query model.Query) []*model.Car {
+	r.RLock()
+	defer r.RUnlock()
+	var cars []*model.Car
+	_, scan := r.plan(query)
+	scan(func(_ string, car *model.Car) {
+		if query.Matches(car) {
+			cars = append(cars, car)
+		}
+	})
+	query.SortCars(cars)
+	return cars
+}
+
+// iterate hands fn a copy of each car in turn. Stored cars are replaced rather
+// than changed by writes, so the copies can be taken without holding a lock.
+func iterate(cars []*model.Car, fn func(car *model.Car) error) error {
+	for _, car := range cars {
+		if err := fn(car.Clone()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Repo) Delete(key string) error {
 	r.Lock()
 	defer r.Unlock()
-	delete(r.db, key)
+	if _, ok := r.db[key]; !ok {
+		return utils.ErrNotFound
+	}
+	r.set(key, nil)
 	return nil
 }
 
 func (r *Repo) Update(key string, object *model.Car) error {
-	car, err := r.Get(key)
-	if err != nil {
+	r.Lock()
+	defer r.Unlock()
+	stored, ok := r.db[key]
+	if !ok {
+		return utils.ErrNotFound
+	}
+	if err := checkVersion(stored, object); err != nil {
 		return err
 	}
+	object.Version = stored.Version + 1
+	r.set(key, object.Clone())
+	return nil
+}
 
+// put stores car as is, for backends that keep their state in a Repo.
+func (r *Repo) put(key string, car *model.Car) {
+	r.Lock()
 	defer r.Unlock()
+	r.set(key, car)
+}
+
+// remove drops key, for backends that keep their state in a Repo.
+func (r *Repo) remove(key string) {
 	r.Lock()
+	defer r.Unlock()
+	r.set(key, nil)
+}
 
-	r.db[car.ID] = object
+// checkVersion enforces the optimistic lock carried in object.Version.
+func checkVersion(stored, object *model.Car) error {
+	if object.Version != 0 && object.Version != stored.Version {
+		return fmt.Errorf("%w: car is at version %d, not %d", utils.ErrPreconditionFailed, stored.Version, object.Version)
+	}
 	return nil
 }
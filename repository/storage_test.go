@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/DalvinCodes/cars/model"
@@ -121,9 +122,7 @@ func TestDelete(t *testing.T) {
 	}
 
 	object, err := repo.Get(mockCar.ID)
-	if err != nil {
-		assert.NoError(t, err)
-	}
+	assert.ErrorIs(t, err, utils.ErrNotFound)
 
 	assert.Nil(t, object)
 }
@@ -147,3 +146,31 @@ func TestGetAll(t *testing.T) {
 
 	assert.Equal(t, len(cars), len(carList))
 }
+
+func TestFind(t *testing.T) {
+	repo := repository.NewRepo()
+
+	carList := []*model.Car{tesla, mustang, mercedes}
+	for i, car := range carList {
+		car.ID = fmt.Sprintf("find-%d", i)
+		if err := repo.Save(car.ID, car); err != nil {
+			assert.NoError(t, err)
+		}
+	}
+
+	t.Run("Filter and sort", func(t *testing.T) {
+		yearMin := 2023
+		cars, err := repo.Find(model.Query{
+			YearMin: &yearMin,
+			Sort:    []model.SortField{{Field: "price", Desc: true}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []*model.Car{mercedes, mustang}, cars)
+	})
+
+	t.Run("Filter by category", func(t *testing.T) {
+		cars, err := repo.Find(model.Query{Category: "coupe"})
+		assert.NoError(t, err)
+		assert.Equal(t, []*model.Car{mustang}, cars)
+	})
+}
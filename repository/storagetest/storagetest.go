@@ -0,0 +1,544 @@
+// Package storagetest checks that a repository.Storage implementation honours
+// the behaviour the service layer relies on. Every backend should pass it:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func() repository.Storage { return NewMyStore() })
+//	}
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory returns a new, empty Storage for every call.
+type Factory func() repository.Storage
+
+// Run runs the whole conformance suite against the storage built by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newStorage()) })
+	t.Run("SaveReplaces", func(t *testing.T) { testSaveReplaces(t, newStorage()) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newStorage()) })
+	t.Run("Ordering", func(t *testing.T) { testOrdering(t, newStorage()) })
+	t.Run("Versioning", func(t *testing.T) { testVersioning(t, newStorage()) })
+	t.Run("SoftDeleted", func(t *testing.T) { testSoftDeleted(t, newStorage()) })
+	t.Run("Transactions", func(t *testing.T) { testTransactions(t, newStorage()) })
+	t.Run("Find", func(t *testing.T) { testFind(t, newStorage()) })
+	t.Run("Iterate", func(t *testing.T) { testIterate(t, newStorage()) })
+	t.Run("Isolation", func(t *testing.T) { testIsolation(t, newStorage()) })
+	t.Run("ConcurrentWriters", func(t *testing.T) { testConcurrentWriters(t, newStorage()) })
+	t.Run("ConcurrentReaders", func(t *testing.T) { testConcurrentReaders(t, newStorage()) })
+}
+
+func newCar(id string) *model.Car {
+	return &model.Car{
+		ID:       id,
+		Make:     "Toyota",
+		Model:    "Camry",
+		Package:  "LE",
+		Color:    "White",
+		Category: "Sedan",
+		Year:     2019,
+		Mileage:  10000,
+		Price:    1500000,
+	}
+}
+
+func testCRUD(t *testing.T, store repository.Storage) {
+	car := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, car))
+
+	object, err := store.Get(car.ID)
+	assert.NoError(t, err)
+	want := newCar("camry")
+	want.Version = 1
+	assert.Equal(t, want, object)
+
+	updated := newCar("camry")
+	updated.Color = "Red"
+	assert.NoError(t, store.Update(car.ID, updated))
+
+	object, err = store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Red", object.Color)
+
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, cars, 1)
+
+	assert.NoError(t, store.Delete(car.ID))
+
+	cars, err = store.GetAll()
+	assert.NoError(t, err)
+	assert.Empty(t, cars)
+}
+
+func testSaveReplaces(t *testing.T, store repository.Storage) {
+	car := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, car))
+
+	replacement := newCar("camry")
+	replacement.Model = "Corolla"
+	assert.NoError(t, store.Save(car.ID, replacement))
+
+	object, err := store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Corolla", object.Model)
+
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, cars, 1)
+}
+
+func testNotFound(t *testing.T, store repository.Storage) {
+	object, err := store.Get("missing")
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+	assert.Nil(t, object)
+
+	assert.ErrorIs(t, store.Update("missing", newCar("missing")), utils.ErrNotFound)
+	assert.ErrorIs(t, store.Delete("missing"), utils.ErrNotFound)
+
+	// a deleted car is gone for every operation
+	car := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, car))
+	assert.NoError(t, store.Delete(car.ID))
+
+	_, err = store.Get(car.ID)
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+	assert.ErrorIs(t, store.Update(car.ID, car), utils.ErrNotFound)
+	assert.ErrorIs(t, store.Delete(car.ID), utils.ErrNotFound)
+}
+
+func testVersioning(t *testing.T, store repository.Storage) {
+	car := newCar("camry")
+	car.Version = 7
+	assert.NoError(t, store.Save(car.ID, car))
+	assert.Equal(t, int64(1), car.Version)
+
+	// an update naming the current version succeeds and bumps it
+	updated := newCar("camry")
+	updated.Version = 1
+	assert.NoError(t, store.Update(car.ID, updated))
+	assert.Equal(t, int64(2), updated.Version)
+
+	// a stale version is rejected and changes nothing
+	stale := newCar("camry")
+	stale.Color = "Red"
+	stale.Version = 1
+	assert.ErrorIs(t, store.Update(car.ID, stale), utils.ErrPreconditionFailed)
+
+	object, err := store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "White", object.Color)
+	assert.Equal(t, int64(2), object.Version)
+
+	// version 0 updates unconditionally
+	unconditional := newCar("camry")
+	assert.NoError(t, store.Update(car.ID, unconditional))
+	assert.Equal(t, int64(3), unconditional.Version)
+
+	// saving over an existing car keeps counting
+	replacement := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, replacement))
+	assert.Equal(t, int64(4), replacement.Version)
+}
+
+func testSoftDeleted(t *testing.T, store repository.Storage) {
+	deletedAt := time.Date(2023, 11, 1, 12, 30, 0, 0, time.UTC)
+	live, deleted := newCar("live"), newCar("deleted")
+	deleted.DeletedAt = &deletedAt
+	assert.NoError(t, store.Save(live.ID, live))
+	assert.NoError(t, store.Save(deleted.ID, deleted))
+
+	// soft deleted cars can still be read directly
+	object, err := store.Get(deleted.ID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, object.DeletedAt) {
+		assert.True(t, deletedAt.Equal(*object.DeletedAt))
+	}
+
+	// and listed in full
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted", "live"}, ids(cars))
+
+	// but are left out of queries unless asked for
+	cars, err = store.Find(model.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"live"}, ids(cars))
+
+	cars, err = store.Find(model.Query{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted", "live"}, ids(cars))
+
+	// clearing DeletedAt restores the car
+	object.DeletedAt = nil
+	assert.NoError(t, store.Update(object.ID, object))
+
+	cars, err = store.Find(model.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted", "live"}, ids(cars))
+}
+
+// testTransactions only reads outside a transaction once it is finished, as a
+// database with a single connection would block until then.
+func testTransactions(t *testing.T, store repository.Storage) {
+	car := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, car))
+
+	// a committed transaction applies every write
+	tx, err := store.Begin()
+	assert.NoError(t, err)
+
+	updated := newCar("camry")
+	updated.Color = "Red"
+	assert.NoError(t, tx.Update(car.ID, updated))
+	assert.Equal(t, int64(2), updated.Version)
+	assert.NoError(t, tx.Save("civic", newCar("civic")))
+
+	stale := newCar("camry")
+	stale.Version = 1
+	assert.ErrorIs(t, tx.Update(car.ID, stale), utils.ErrPreconditionFailed)
+
+	object, err := tx.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Red", object.Color)
+
+	cars, err := tx.Find(model.Query{Color: "red"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"camry"}, ids(cars))
+
+	var iterated []*model.Car
+	assert.NoError(t, tx.Iterate(model.Query{}, func(car *model.Car) error {
+		iterated = append(iterated, car)
+		return nil
+	}))
+	assert.Equal(t, []string{"camry", "civic"}, ids(iterated))
+
+	assert.NoError(t, tx.Commit())
+	assert.ErrorIs(t, tx.Commit(), utils.ErrTxDone)
+
+	cars, err = store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"camry", "civic"}, ids(cars))
+	assert.Equal(t, "Red", cars[0].Color)
+	assert.Equal(t, int64(2), cars[0].Version)
+
+	// a rolled back transaction leaves no trace
+	tx, err = store.Begin()
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Delete(car.ID))
+	assert.NoError(t, tx.Save("corolla", newCar("corolla")))
+
+	_, err = tx.Get(car.ID)
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+	cars, err = tx.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"civic", "corolla"}, ids(cars))
+
+	assert.NoError(t, tx.Rollback())
+	assert.ErrorIs(t, tx.Rollback(), utils.ErrTxDone)
+
+	cars, err = store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"camry", "civic"}, ids(cars))
+
+	// WithTx rolls back when the work fails
+	err = repository.WithTx(store, func(tx repository.Tx) error {
+		if err := tx.Delete("civic"); err != nil {
+			return err
+		}
+		return tx.Delete("missing")
+	})
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+
+	_, err = store.Get("civic")
+	assert.NoError(t, err)
+}
+
+func testOrdering(t *testing.T, store repository.Storage) {
+	prices := map[string]int{"d": 300, "b": 100, "e": 200, "a": 200, "c": 100}
+	for _, id := range []string{"d", "b", "e", "a", "c"} {
+		car := newCar(id)
+		car.Price = prices[id]
+		assert.NoError(t, store.Save(id, car))
+	}
+
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids(cars))
+
+	// ties on the sort fields are broken by ID
+	cars, err = store.Find(model.Query{Sort: []model.SortField{{Field: "price"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "a", "e", "d"}, ids(cars))
+
+	cars, err = store.Find(model.Query{Sort: []model.SortField{{Field: "price", Desc: true}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "a", "e", "b", "c"}, ids(cars))
+}
+
+func testFind(t *testing.T, store repository.Storage) {
+	civic := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Color: "Red", Category: "Sedan", Year: 2019, Mileage: 12000, Price: 2000000}
+	accord := &model.Car{ID: "accord", Make: "Honda", Model: "Accord", Color: "Blue", Category: "Sedan", Year: 2021, Mileage: 100, Price: 3000000}
+	tacoma := &model.Car{ID: "tacoma", Make: "Toyota", Model: "Tacoma", Color: "Red", Category: "Truck", Year: 2021, Mileage: 0, Price: 3500000}
+	octavia := &model.Car{ID: "octavia", Make: "Škoda", Model: "Octavia", Color: "Šedá", Category: "Wagon", Year: 2015, Mileage: 80000, Price: 1500000}
+	for _, car := range []*model.Car{civic, accord, tacoma, octavia} {
+		assert.NoError(t, store.Save(car.ID, car))
+	}
+
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name  string
+		query model.Query
+		want  []string
+	}{
+		{"Everything", model.Query{}, []string{"accord", "civic", "octavia", "tacoma"}},
+		{"Make ignores case", model.Query{Make: "HONDA"}, []string{"accord", "civic"}},
+		{"Case is ignored beyond ASCII", model.Query{Make: "škoda", Color: "ŠEDÁ"}, []string{"octavia"}},
+		{"Color and category", model.Query{Color: "red", Category: "truck"}, []string{"tacoma"}},
+		{"Year range", model.Query{YearMin: intPtr(2020), YearMax: intPtr(2021)}, []string{"accord", "tacoma"}},
+		{"Price range", model.Query{PriceMin: intPtr(2500000), PriceMax: intPtr(3000000)}, []string{"accord"}},
+		{"Zero mileage bound", model.Query{MileageMax: intPtr(0)}, []string{"tacoma"}},
+		{"No match", model.Query{Model: "Camry"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cars, err := store.Find(tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ids(cars))
+		})
+	}
+}
+
+func testIterate(t *testing.T, store repository.Storage) {
+	for _, id := range []string{"c", "a", "b"} {
+		assert.NoError(t, store.Save(id, newCar(id)))
+	}
+	deleted := newCar("d")
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleted.DeletedAt = &deletedAt
+	assert.NoError(t, store.Save(deleted.ID, deleted))
+
+	// the cars come in the order Find returns them
+	var seen []*model.Car
+	err := store.Iterate(model.Query{}, func(car *model.Car) error {
+		seen = append(seen, car)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(seen))
+
+	// changing a car handed to fn does not reach the storage
+	seen[0].Color = "Iterated"
+	object, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "White", object.Color)
+
+	// an error from fn stops the iteration
+	stop := errors.New("stop")
+	calls := 0
+	err = store.Iterate(model.Query{IncludeDeleted: true}, func(car *model.Car) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+
+	// the storage is usable once the iteration ends
+	assert.NoError(t, store.Delete("a"))
+}
+
+func testIsolation(t *testing.T, store repository.Storage) {
+	car := newCar("camry")
+	assert.NoError(t, store.Save(car.ID, car))
+
+	// changing the saved car afterwards does not reach the storage
+	car.Color = "Saved"
+
+	object, err := store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "White", object.Color)
+
+	// neither does changing a car handed out by a read
+	object.Color = "Got"
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, "White", cars[0].Color)
+
+	cars[0].Color = "Listed"
+	found, err := store.Find(model.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, "White", found[0].Color)
+
+	found[0].Color = "Found"
+	updated := newCar("camry")
+	assert.NoError(t, store.Update(car.ID, updated))
+	updated.Color = "Updated"
+
+	object, err = store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "White", object.Color)
+
+	// nor does changing a car handed out by Iterate or a transaction
+	assert.NoError(t, store.Iterate(model.Query{}, func(car *model.Car) error {
+		car.Color = "Iterated"
+		return nil
+	}))
+	assert.NoError(t, repository.WithTx(store, func(tx repository.Tx) error {
+		object, err := tx.Get(car.ID)
+		if err != nil {
+			return err
+		}
+		object.Color = "InTx"
+		return nil
+	}))
+
+	// or changing when a car was deleted through the pointer
+	deletedAt := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	deleted := newCar("camry")
+	deleted.DeletedAt = &time.Time{}
+	*deleted.DeletedAt = deletedAt
+	assert.NoError(t, store.Update(car.ID, deleted))
+	*deleted.DeletedAt = deletedAt.Add(time.Hour)
+
+	object, err = store.Get(car.ID)
+	assert.NoError(t, err)
+	*object.DeletedAt = deletedAt.Add(2 * time.Hour)
+
+	object, err = store.Get(car.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "White", object.Color)
+	assert.True(t, deletedAt.Equal(*object.DeletedAt))
+}
+
+func testConcurrentWriters(t *testing.T, store repository.Storage) {
+	const writers = 8
+	const carsPerWriter = 25
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < carsPerWriter; i++ {
+				id := fmt.Sprintf("car-%d-%d", w, i)
+				assert.NoError(t, store.Save(id, newCar(id)))
+
+				car := newCar(id)
+				car.Mileage = i
+				assert.NoError(t, store.Update(id, car))
+
+				// every writer also reads what the others are doing
+				_, err := store.GetAll()
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	cars, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, cars, writers*carsPerWriter)
+}
+
+// testConcurrentReaders has readers change every car they are handed while
+// writers change the cars they handed in. Run under the race detector, it
+// shows that no reader or writer shares a car with the storage or each other.
+func testConcurrentReaders(t *testing.T, store repository.Storage) {
+	const cars = 10
+	const rounds = 20
+
+	deletedAt := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < cars; i++ {
+		car := newCar(fmt.Sprintf("car-%d", i))
+		if i%2 == 0 {
+			car.DeletedAt = &time.Time{}
+			*car.DeletedAt = deletedAt
+		}
+		assert.NoError(t, store.Save(car.ID, car))
+	}
+
+	// scribble changes a car the way a careless handler might
+	scribble := func(car *model.Car) {
+		car.Make = "Scribbled"
+		if car.DeletedAt != nil {
+			*car.DeletedAt = time.Time{}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				car := newCar(fmt.Sprintf("car-%d", (w+i)%cars))
+				car.Mileage = i
+				car.DeletedAt = &time.Time{}
+				*car.DeletedAt = deletedAt
+				if i%2 == 0 {
+					assert.NoError(t, store.Save(car.ID, car))
+				} else {
+					assert.NoError(t, store.Update(car.ID, car))
+				}
+				scribble(car)
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				car, err := store.Get(fmt.Sprintf("car-%d", (w+i)%cars))
+				assert.NoError(t, err)
+				scribble(car)
+
+				all, err := store.GetAll()
+				assert.NoError(t, err)
+				for _, car := range all {
+					scribble(car)
+				}
+
+				found, err := store.Find(model.Query{IncludeDeleted: true, Make: "Toyota"})
+				assert.NoError(t, err)
+				for _, car := range found {
+					scribble(car)
+				}
+
+				assert.NoError(t, store.Iterate(model.Query{IncludeDeleted: true}, func(car *model.Car) error {
+					scribble(car)
+					return nil
+				}))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	all, err := store.Find(model.Query{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, all, cars)
+	for _, car := range all {
+		assert.Equal(t, "Toyota", car.Make)
+		if assert.NotNil(t, car.DeletedAt) {
+			assert.True(t, deletedAt.Equal(*car.DeletedAt), "car %s deleted at %v", car.ID, car.DeletedAt)
+		}
+	}
+}
+
+func ids(cars []*model.Car) []string {
+	var ids []string
+	for _, car := range cars {
+		ids = append(ids, car.ID)
+	}
+	return ids
+}
@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/utils"
+)
+
+// Store holds the car operations that storage and transactions share.
+//
+// Iterate hands fn the cars Find would return, in the same order, one at a
+// time rather than all at once. It stops at the first error fn returns and
+// returns it. fn must not use the store it iterates: a database backend keeps
+// its connection busy until the iteration ends.
+type Store interface {
+	Save(key string, object *model.Car) error
+	Get(key string) (*model.Car, error)
+	GetAll() ([]*model.Car, error)
+	Find(query model.Query) ([]*model.Car, error)
+	Iterate(query model.Query, fn func(car *model.Car) error) error
+	Delete(key string) error
+	Update(key string, value *model.Car) error
+}
+
+// Tx is a unit of work on a Storage. Its reads see its own writes, which reach
+// the storage all together on Commit or not at all on Rollback. Committing or
+// rolling back a finished Tx returns utils.ErrTxDone.
+type Tx interface {
+	Store
+	Commit() error
+	Rollback() error
+}
+
+// WithTx runs fn in a transaction on storage. The transaction is committed
+// when fn returns nil and rolled back when it returns an error or panics.
+func WithTx(storage Storage, fn func(tx Tx) error) error {
+	tx, err := storage.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("error while rolling back the transaction. err: %v\n", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// txWrite is a write made in a transaction: op is opSave, opUpdate or
+// opDelete, and car is a copy of the car written, nil for a delete.
+type txWrite struct {
+	op  string
+	key string
+	car *model.Car
+}
+
+// watchedTx is the transaction a decorator hands out to keep up with the
+// writes it does not see. It passes the writes on to the underlying
+// transaction and, once that commits, hands the ones that went through to
+// committed. The decorator's own locks are only taken there, after the commit,
+// since a write holding one of them may be waiting for the transaction.
+type watchedTx struct {
+	Tx
+	committed func(writes []txWrite)
+	writes    []txWrite
+}
+
+func watchTx(tx Tx, committed func(writes []txWrite)) *watchedTx {
+	return &watchedTx{
+		Tx:        tx,
+		committed: committed,
+	}
+}
+
+func (tx *watchedTx) Save(key string, object *model.Car) error {
+	if err := tx.Tx.Save(key, object); err != nil {
+		return err
+	}
+	tx.writes = append(tx.writes, txWrite{op: opSave, key: key, car: object.Clone()})
+	return nil
+}
+
+func (tx *watchedTx) Update(key string, object *model.Car) error {
+	if err := tx.Tx.Update(key, object); err != nil {
+		return err
+	}
+	tx.writes = append(tx.writes, txWrite{op: opUpdate, key: key, car: object.Clone()})
+	return nil
+}
+
+func (tx *watchedTx) Delete(key string) error {
+	if err := tx.Tx.Delete(key); err != nil {
+		return err
+	}
+	tx.writes = append(tx.writes, txWrite{op: opDelete, key: key})
+	return nil
+}
+
+func (tx *watchedTx) Commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	tx.committed(tx.writes)
+	return nil
+}
+
+// Unwrap returns the underlying transaction.
+func (tx *watchedTx) Unwrap() Tx {
+	return tx.Tx
+}
+
+// asTx looks for a T among tx and the transactions it wraps, the way As does
+// for storages.
+func asTx[T any](tx Tx) (T, bool) {
+	for tx != nil {
+		if found, ok := tx.(T); ok {
+			return found, true
+		}
+		wrapper, ok := tx.(interface{ Unwrap() Tx })
+		if !ok {
+			break
+		}
+		tx = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// writtenKeys lists the keys of writes, each once.
+func writtenKeys(writes []txWrite) []string {
+	seen := make(map[string]bool, len(writes))
+	keys := make([]string, 0, len(writes))
+	for _, write := range writes {
+		if !seen[write.key] {
+			seen[write.key] = true
+			keys = append(keys, write.key)
+		}
+	}
+	return keys
+}
+
+func (r *Repo) Begin() (Tx, error) {
+	return r.begin(), nil
+}
+
+func (r *Repo) begin() *repoTx {
+	return &repoTx{
+		repo:   r,
+		writes: make(map[string]*model.Car),
+		seen:   make(map[string]*model.Car),
+	}
+}
+
+// repoTx keeps its writes in an overlay on top of the Repo and copies them in
+// on commit. It remembers the stored car it first saw under every key it
+// touches and keeps reading that one, and the commit fails with
+// utils.ErrConflict if any key it wrote changed in the Repo since.
+type repoTx struct {
+	repo *Repo
+
+	mu     sync.Mutex
+	writes map[string]*model.Car // nil for deleted keys
+	keys   []string              // written keys in the order of their first write
+	seen   map[string]*model.Car // nil for keys that were missing
+	done   bool
+}
+
+func (tx *repoTx) Save(key string, object *model.Car) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return utils.ErrTxDone
+	}
+
+	next := object.Clone()
+	next.Version = 1
+	if stored := tx.lookup(key); stored != nil {
+		next.Version = stored.Version + 1
+	}
+	tx.write(key, next)
+	object.Version = next.Version
+	return nil
+}
+
+func (tx *repoTx) Get(key string) (*model.Car, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, utils.ErrTxDone
+	}
+
+	car := tx.lookup(key)
+	if car == nil {
+		return nil, utils.ErrNotFound
+	}
+	return car.Clone(), nil
+}
+
+func (tx *repoTx) GetAll() ([]*model.Car, error) {
+	return tx.Find(model.Query{IncludeDeleted: true})
+}
+
+func (tx *repoTx) Find(query model.Query) ([]*model.Car, error) {
+	cars, err := tx.matching(query)
+	if err != nil {
+		return nil, err
+	}
+	for i, car := range cars {
+		cars[i] = car.Clone()
+	}
+	return cars, nil
+}
+
+func (tx *repoTx) Iterate(query model.Query, fn func(car *model.Car) error) error {
+	cars, err := tx.matching(query)
+	if err != nil {
+		return err
+	}
+	return iterate(cars, fn)
+}
+
+// matching returns the cars the transaction sees that match query, sorted.
+// They are shared with the transaction and the Repo.
+func (tx *repoTx) matching(query model.Query) ([]*model.Car, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, utils.ErrTxDone
+	}
+
+	// lay what the transaction saw and wrote over the cars the Repo's plan
+	// picks; the overlay is checked against the query like the rest
+	visible := make(map[string]*model.Car)
+	tx.repo.RLock()
+	_, scan := tx.repo.plan(query)
+	scan(func(key string, car *model.Car) {
+		visible[key] = car
+	})
+	tx.repo.RUnlock()
+	for key, car := range tx.seen {
+		visible[key] = car
+	}
+	for key, car := range tx.writes {
+		visible[key] = car
+	}
+
+	var cars []*model.Car
+	for _, car := range visible {
+		if car != nil && query.Matches(car) {
+			cars = append(cars, car)
+		}
+	}
+	query.SortCars(cars)
+	return cars, nil
+}
+
+func (tx *repoTx) Delete(key string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return utils.ErrTxDone
+	}
+
+	if tx.lookup(key) == nil {
+		return utils.ErrNotFound
+	}
+	tx.write(key, nil)
+	return nil
+}
+
+func (tx *repoTx) Update(key string, object *model.Car) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return utils.ErrTxDone
+	}
+
+	stored := tx.lookup(key)
+	if stored == nil {
+		return utils.ErrNotFound
+	}
+	if err := checkVersion(stored, object); err != nil {
+		return err
+	}
+
+	next := object.Clone()
+	next.Version = stored.Version + 1
+	tx.write(key, next)
+	object.Version = next.Version
+	return nil
+}
+
+func (tx *repoTx) Commit() error {
+	return tx.commit(nil)
+}
+
+// commit copies the writes into the Repo. journal, when set, is handed the
+// writes once they are known not to conflict and before anyone can see them,
+// so a backend can make them durable first; if it fails nothing is applied.
+func (tx *repoTx) commit(journal func(keys []string, writes map[string]*model.Car) error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return utils.ErrTxDone
+	}
+	tx.done = true
+
+	tx.repo.Lock()
+	defer tx.repo.Unlock()
+	if err := tx.conflict(); err != nil {
+		return err
+	}
+
+	if journal != nil && len(tx.keys) > 0 {
+		if err := journal(tx.keys, tx.writes); err != nil {
+			return err
+		}
+	}
+	tx.apply()
+	return nil
+}
+
+// conflict reports the first key the transaction wrote that changed in the
+// Repo since it was seen. The caller holds the Repo's write lock.
+func (tx *repoTx) conflict() error {
+	for _, key := range tx.keys {
+		if tx.repo.db[key] != tx.seen[key] {
+			return fmt.Errorf("%w: car %s changed during the transaction", utils.ErrConflict, key)
+		}
+	}
+	return nil
+}
+
+// apply copies the writes into the Repo. The caller holds the Repo's write
+// lock.
+func (tx *repoTx) apply() {
+	for _, key := range tx.keys {
+		tx.repo.set(key, tx.writes[key])
+	}
+}
+
+func (tx *repoTx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return utils.ErrTxDone
+	}
+	tx.done = true
+	return nil
+}
+
+// lookup returns the car the transaction sees under key, or nil. The caller
+// holds tx.mu.
+func (tx *repoTx) lookup(key string) *model.Car {
+	if car, ok := tx.writes[key]; ok {
+		return car
+	}
+	if car, ok := tx.seen[key]; ok {
+		return car
+	}
+
+	tx.repo.RLock()
+	car := tx.repo.db[key]
+	tx.repo.RUnlock()
+	tx.seen[key] = car
+	return car
+}
+
+// write records car, or nil for a delete, under key. The caller holds tx.mu
+// and has looked key up.
+func (tx *repoTx) write(key string, car *model.Car) {
+	if _, ok := tx.writes[key]; !ok {
+		tx.keys = append(tx.keys, key)
+	}
+	tx.writes[key] = car
+}
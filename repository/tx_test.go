@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoTx(t *testing.T) {
+	t.Run("Concurrent writes to the same car conflict", func(t *testing.T) {
+		repo := repository.NewRepo()
+		assert.NoError(t, repo.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		first, err := repo.Begin()
+		assert.NoError(t, err)
+		second, err := repo.Begin()
+		assert.NoError(t, err)
+
+		assert.NoError(t, first.Update("civic", &model.Car{ID: "civic", Make: "Honda", Price: 1}))
+		assert.NoError(t, second.Update("civic", &model.Car{ID: "civic", Make: "Honda", Price: 2}))
+
+		assert.NoError(t, first.Commit())
+		assert.ErrorIs(t, second.Commit(), utils.ErrConflict)
+
+		object, err := repo.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, object.Price)
+	})
+
+	t.Run("Uncommitted writes are invisible outside", func(t *testing.T) {
+		repo := repository.NewRepo()
+
+		tx, err := repo.Begin()
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		_, err = repo.Get("civic")
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+
+		assert.NoError(t, tx.Commit())
+		_, err = repo.Get("civic")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Reads repeat inside a transaction", func(t *testing.T) {
+		repo := repository.NewRepo()
+		assert.NoError(t, repo.Save("civic", &model.Car{ID: "civic", Make: "Honda", Price: 1}))
+
+		tx, err := repo.Begin()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.Get("civic")
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Update("civic", &model.Car{ID: "civic", Make: "Honda", Price: 2}))
+
+		object, err := tx.Get("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, object.Price)
+	})
+}
+
+func TestFileStoreTx(t *testing.T) {
+	t.Run("Committed transactions survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newFileStore(t, dir)
+		assert.NoError(t, store.Save("civic", &model.Car{ID: "civic", Make: "Honda"}))
+
+		err := repository.WithTx(store, func(tx repository.Tx) error {
+			if err := tx.Delete("civic"); err != nil {
+				return err
+			}
+			return tx.Save("camry", &model.Car{ID: "camry", Make: "Toyota"})
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Close())
+
+		reopened := newFileStore(t, dir)
+		defer reopened.Close()
+
+		cars, err := reopened.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+		assert.Equal(t, "camry", cars[0].ID)
+	})
+}
+
+func TestHistoryStoreTx(t *testing.T) {
+	store := repository.NewHistoryStore(repository.NewRepo(), time.Now)
+
+	err := repository.WithTx(store, func(tx repository.Tx) error {
+		return tx.Save("civic", &model.Car{ID: "civic", Make: "Honda"})
+	})
+	assert.NoError(t, err)
+
+	err = repository.WithTx(store, func(tx repository.Tx) error {
+		if err := tx.Save("camry", &model.Car{ID: "camry", Make: "Toyota"}); err != nil {
+			return err
+		}
+		return tx.Delete("missing")
+	})
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+
+	revisions, err := store.Revisions("civic")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+
+	_, err = store.Revisions("camry")
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type contextKey string
+
+const paramsKey contextKey = "params"
+
+// Router dispatches requests by method and path. Patterns are split on "/" and
+// a segment written as {name} matches any single path segment, which handlers
+// read back with Param. When several patterns match, the one with a literal
+// segment where the others have a parameter wins.
+type Router struct {
+	routes []*route
+
+	// NotFound answers requests whose path matches no pattern.
+	NotFound http.HandlerFunc
+	// MethodNotAllowed answers requests whose path matches a pattern that has no
+	// handler for the method. The Allow header is already set when it runs.
+	MethodNotAllowed http.HandlerFunc
+}
+
+type route struct {
+	pattern  string
+	segments []string
+	handlers map[string]http.HandlerFunc
+}
+
+func New() *Router {
+	return &Router{
+		NotFound: http.NotFound,
+		MethodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		},
+	}
+}
+
+// Handle registers handler for method on pattern, e.g. "/api/v1/cars/{id}".
+func (rt *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	for _, existing := range rt.routes {
+		if existing.pattern == pattern {
+			existing.handlers[method] = handler
+			return
+		}
+	}
+
+	rt.routes = append(rt.routes, &route{
+		pattern:  pattern,
+		segments: strings.Split(pattern, "/"),
+		handlers: map[string]http.HandlerFunc{method: handler},
+	})
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	matched, params := rt.match(r.URL.Path)
+	if matched == nil {
+		rt.NotFound(w, r)
+		return
+	}
+
+	handler, ok := matched.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		handler, ok = matched.handlers[http.MethodGet]
+	}
+	if !ok {
+		w.Header().Set("Allow", matched.allow())
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rt.MethodNotAllowed(w, r)
+		return
+	}
+
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey, params))
+	}
+	handler(w, r)
+}
+
+// Param returns the value of the named path parameter, or "" when the route
+// has no such parameter.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey).(map[string]string)
+	return params[name]
+}
+
+// match finds the most specific route for path.
+func (rt *Router) match(path string) (*route, map[string]string) {
+	segments := strings.Split(path, "/")
+
+	var best *route
+	var bestParams map[string]string
+	for _, candidate := range rt.routes {
+		params, ok := candidate.match(segments)
+		if !ok {
+			continue
+		}
+		if best == nil || candidate.moreSpecific(best) {
+			best, bestParams = candidate, params
+		}
+	}
+	return best, bestParams
+}
+
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, segment := range rt.segments {
+		if name, ok := paramName(segment); ok {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// moreSpecific reports whether rt has a literal segment at the first position
+// where it and other differ in kind.
+func (rt *route) moreSpecific(other *route) bool {
+	for i := range rt.segments {
+		_, isParam := paramName(rt.segments[i])
+		_, otherIsParam := paramName(other.segments[i])
+		if isParam != otherIsParam {
+			return otherIsParam
+		}
+	}
+	return false
+}
+
+func (rt *route) allow() string {
+	methods := []string{http.MethodOptions}
+	for method := range rt.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := rt.handlers[http.MethodGet]; ok {
+		if _, ok := rt.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
@@ -0,0 +1,84 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DalvinCodes/cars/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func respond(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body + router.Param(r, "id")))
+	}
+}
+
+func newTestRouter() *router.Router {
+	mux := router.New()
+	mux.Handle(http.MethodGet, "/cars", respond("list"))
+	mux.Handle(http.MethodPost, "/cars", respond("create"))
+	mux.Handle(http.MethodGet, "/cars/{id}", respond("get "))
+	mux.Handle(http.MethodDelete, "/cars/{id}", respond("delete "))
+	mux.Handle(http.MethodGet, "/cars/all", respond("all"))
+	return mux
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		body   string
+		allow  string
+	}{
+		{"Collection", "GET", "/cars", http.StatusOK, "list", ""},
+		{"Method on collection", "POST", "/cars", http.StatusOK, "create", ""},
+		{"Path parameter", "GET", "/cars/42", http.StatusOK, "get 42", ""},
+		{"Literal beats parameter", "GET", "/cars/all", http.StatusOK, "all", ""},
+		{"HEAD falls back to GET", "HEAD", "/cars/42", http.StatusOK, "", ""},
+		{"Unsupported method", "PUT", "/cars/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"Options", "OPTIONS", "/cars", http.StatusNoContent, "", "GET, HEAD, OPTIONS, POST"},
+		{"Unknown path", "GET", "/trucks", http.StatusNotFound, "", ""},
+		{"Empty parameter", "GET", "/cars/", http.StatusNotFound, "", ""},
+		{"Too many segments", "GET", "/cars/42/wheels", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			rr := httptest.NewRecorder()
+			mux := newTestRouter()
+
+			// When
+			req, err := http.NewRequest(tt.method, tt.target, nil)
+			assert.NoError(t, err)
+
+			mux.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+			assert.Equal(t, tt.allow, rr.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestRouterCustomHandlers(t *testing.T) {
+	mux := newTestRouter()
+	mux.NotFound = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }
+	mux.MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusConflict) }
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/trucks", nil))
+	assert.Equal(t, http.StatusTeapot, rr.Code)
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("PATCH", "/cars", nil))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", rr.Header().Get("Allow"))
+}
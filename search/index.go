@@ -0,0 +1,310 @@
+// Package search is an in-memory full-text index over cars. It matches query
+// words against the words of a car's make, model, package, color, category and
+// year exactly, by prefix or with a typo or two, and ranks the cars by how many
+// query words they match and how well.
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/DalvinCodes/cars/model"
+)
+
+// fields lists what is indexed and how much a match in each counts.
+var fields = []struct {
+	weight float64
+	text   func(car *model.Car) string
+}{
+	{3, func(car *model.Car) string { return car.Model }},
+	{2.5, func(car *model.Car) string { return car.Make }},
+	{2, func(car *model.Car) string { return car.Package }},
+	{1.5, func(car *model.Car) string { return car.Color }},
+	{1, func(car *model.Car) string { return car.Category }},
+	{1.5, func(car *model.Car) string {
+		if car.Year == 0 {
+			return ""
+		}
+		return strconv.Itoa(car.Year)
+	}},
+}
+
+// How much a match counts depending on how the query word found the term.
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.6
+	fuzzyMatch  = 0.4 // per edit, so two edits count 0.2
+)
+
+// Result is a car that matched a search.
+type Result struct {
+	Key     string
+	Car     *model.Car // a copy of the car as indexed
+	Score   float64
+	Matched int // how many query words matched
+}
+
+// Index maps the words of cars to their keys. It keeps a copy of every car it
+// indexes, so searches can filter and return the cars without looking them up
+// elsewhere. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]float64 // term -> key -> weight of its best field
+	docs     map[string]*document          // key -> the car indexed under it
+	terms    []string                      // every term, sorted
+}
+
+// document is a car in the index.
+type document struct {
+	car   *model.Car
+	terms []string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]float64),
+		docs:     make(map[string]*document),
+	}
+}
+
+// Add indexes car under key, replacing what was indexed under it before.
+func (ix *Index) Add(key string, car *model.Car) {
+	weights := make(map[string]float64)
+	for _, field := range fields {
+		for _, term := range Tokenize(field.text(car)) {
+			if field.weight > weights[term] {
+				weights[term] = field.weight
+			}
+		}
+	}
+
+	doc := &document{car: car.Clone()}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+	ix.docs[key] = doc
+	for term, weight := range weights {
+		keys, ok := ix.postings[term]
+		if !ok {
+			keys = make(map[string]float64)
+			ix.postings[term] = keys
+			ix.insertTerm(term)
+		}
+		keys[key] = weight
+		doc.terms = append(doc.terms, term)
+	}
+}
+
+// Remove drops key from the index.
+func (ix *Index) Remove(key string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+}
+
+// Len returns how many cars are indexed.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search returns the cars matching any word of text for which match, unless it
+// is nil, returns true. The ones matching the most words come first, then by
+// score and key. limit caps the results unless it is 0.
+func (ix *Index) Search(text string, match func(car *model.Car) bool, limit int) []Result {
+	words := Tokenize(text)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	results := make(map[string]*Result)
+	for _, word := range words {
+		// a car scores for the best way the word matches one of its terms
+		best := make(map[string]float64)
+		for term, quality := range ix.matches(word) {
+			idf := math.Log(1 + float64(len(ix.docs))/float64(len(ix.postings[term])))
+			for key, weight := range ix.postings[term] {
+				if score := quality * weight * idf; score > best[key] {
+					best[key] = score
+				}
+			}
+		}
+
+		for key, score := range best {
+			result, ok := results[key]
+			if !ok {
+				result = &Result{Key: key}
+				results[key] = result
+			}
+			result.Score += score
+			result.Matched++
+		}
+	}
+
+	ranked := make([]Result, 0, len(results))
+	for key, result := range results {
+		if match == nil || match(ix.docs[key].car) {
+			ranked = append(ranked, *result)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Matched != b.Matched {
+			return a.Matched > b.Matched
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Key < b.Key
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	for i := range ranked {
+		ranked[i].Car = ix.docs[ranked[i].Key].car.Clone()
+	}
+	return ranked
+}
+
+// matches finds the terms word matches and how well. The caller holds ix.mu.
+func (ix *Index) matches(word string) map[string]float64 {
+	found := make(map[string]float64)
+	add := func(term string, quality float64) {
+		if quality > found[term] {
+			found[term] = quality
+		}
+	}
+
+	// terms starting with the word are sorted right after where it would go
+	for i := sort.SearchStrings(ix.terms, word); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], word); i++ {
+		if ix.terms[i] == word {
+			add(word, exactMatch)
+		} else {
+			add(ix.terms[i], prefixMatch)
+		}
+	}
+
+	if maxEdits := allowedEdits(word); maxEdits > 0 {
+		for _, term := range ix.terms {
+			if edits, ok := distance(word, term, maxEdits); ok && edits > 0 {
+				add(term, fuzzyMatch/float64(edits))
+			}
+		}
+	}
+	return found
+}
+
+// remove drops key. The caller holds ix.mu.
+func (ix *Index) remove(key string) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		keys := ix.postings[term]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(ix.postings, term)
+			ix.deleteTerm(term)
+		}
+	}
+	delete(ix.docs, key)
+}
+
+func (ix *Index) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) deleteTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// Tokenize splits s into lower case words of letters and digits.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// allowedEdits is how many typos a word may have and still match: none for
+// short words and numbers, where one edit makes a different word, one from
+// four letters and two from eight.
+func allowedEdits(word string) int {
+	n := len([]rune(word))
+	if _, err := strconv.Atoi(word); err == nil {
+		return 0
+	}
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// distance is the number of insertions, deletions, substitutions and swaps of
+// neighbouring letters that turn a into b, if it is at most maxEdits.
+func distance(a, b string, maxEdits int) (int, bool) {
+	s, t := []rune(a), []rune(b)
+	if diff := len(s) - len(t); diff > maxEdits || -diff > maxEdits {
+		return 0, false
+	}
+
+	// rows of the edit distance table: two rows back, the last and this one
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				curr[j] = minInt(curr[j], prev2[j-2]+1)
+			}
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > maxEdits {
+			return 0, false
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	if prev[len(t)] > maxEdits {
+		return 0, false
+	}
+	return prev[len(t)], true
+}
+
+func minInt(values ...int) int {
+	smallest := values[0]
+	for _, v := range values[1:] {
+		if v < smallest {
+			smallest = v
+		}
+	}
+	return smallest
+}
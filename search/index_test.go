@@ -0,0 +1,90 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func keys(results []search.Result) []string {
+	out := []string{}
+	for _, result := range results {
+		out = append(out, result.Key)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"ford", "f", "150", "crew", "cab"}, search.Tokenize("Ford F-150  Crew/Cab"))
+	assert.Empty(t, search.Tokenize(" -- "))
+}
+
+func TestSearch(t *testing.T) {
+	index := search.NewIndex()
+	index.Add("civic-sport", &model.Car{Make: "Honda", Model: "Civic", Package: "Sport", Color: "Red", Category: "Sedan", Year: 2019})
+	index.Add("civic-lx", &model.Car{Make: "Honda", Model: "Civic", Package: "LX", Color: "Blue", Category: "Sedan", Year: 2021})
+	index.Add("accord", &model.Car{Make: "Honda", Model: "Accord", Package: "Sport", Color: "Red", Category: "Sedan", Year: 2019})
+	index.Add("corvette", &model.Car{Make: "Chevrolet", Model: "Corvette", Color: "Red", Category: "Coupe", Year: 2020})
+
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"Every word matches the best car", "red civic sport 2019", 0, []string{"civic-sport", "accord", "civic-lx", "corvette"}},
+		{"Case does not matter", "CIVIC", 0, []string{"civic-lx", "civic-sport"}},
+		{"Prefix", "chev", 0, []string{"corvette"}},
+		{"One typo", "acord", 0, []string{"accord"}},
+		{"Swapped letters", "hnoda civic", 0, []string{"civic-lx", "civic-sport", "accord"}},
+		{"Two typos in a long word", "chevorlte", 0, []string{"corvette"}},
+		{"No typos in short words", "lz", 0, []string{}},
+		{"No typos in numbers", "2018", 0, []string{}},
+		{"Limit", "red", 2, []string{"accord", "civic-sport"}},
+		{"No words", "!!", 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, keys(index.Search(tt.text, nil, tt.limit)))
+		})
+	}
+
+	t.Run("Filters before the limit", func(t *testing.T) {
+		blue := func(car *model.Car) bool { return car.Color == "Blue" }
+		assert.Equal(t, []string{"civic-lx"}, keys(index.Search("honda", blue, 1)))
+	})
+
+	t.Run("Results carry a copy of the car", func(t *testing.T) {
+		results := index.Search("corvette", nil, 0)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, "Chevrolet", results[0].Car.Make)
+			results[0].Car.Make = "Ford"
+		}
+		assert.Equal(t, "Chevrolet", index.Search("corvette", nil, 0)[0].Car.Make)
+	})
+
+	t.Run("Exact matches outrank prefix and fuzzy ones", func(t *testing.T) {
+		index := search.NewIndex()
+		index.Add("exact", &model.Car{Model: "Sport"})
+		index.Add("prefix", &model.Car{Model: "Sportage"})
+		index.Add("fuzzy", &model.Car{Model: "Spork"})
+
+		assert.Equal(t, []string{"exact", "prefix", "fuzzy"}, keys(index.Search("sport", nil, 0)))
+	})
+
+	t.Run("Re-adding replaces and removing forgets", func(t *testing.T) {
+		index := search.NewIndex()
+		index.Add("car", &model.Car{Make: "Honda", Model: "Civic"})
+		index.Add("car", &model.Car{Make: "Kia", Model: "Soul"})
+
+		assert.Empty(t, index.Search("civic", nil, 0))
+		assert.Equal(t, []string{"car"}, keys(index.Search("soul", nil, 0)))
+
+		index.Remove("car")
+		assert.Empty(t, index.Search("soul", nil, 0))
+		assert.Zero(t, index.Len())
+	})
+}
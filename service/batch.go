@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
+)
+
+// errBatchFailed rolls back an atomic batch once an operation has failed.
+var errBatchFailed = errors.New("batch operation failed")
+
+// ApplyBatch runs the operations in order and reports the outcome of each.
+// Without atomic every operation stands on its own. With atomic the batch is
+// checked before anything is written and then runs in one transaction: when an
+// operation fails nothing is written and every other operation reports
+// utils.ErrAborted. The error is only set when the batch as a whole is invalid.
+func (c *carService) ApplyBatch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, utils.ErrEmptyInput
+	}
+	if len(ops) > model.MaxBatchSize {
+		return nil, fmt.Errorf("%w: a batch holds at most %d operations", utils.ErrBadBody, model.MaxBatchSize)
+	}
+
+	results := make([]model.BatchResult, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			results[i] = c.applyAlone(op)
+		}
+		return results, nil
+	}
+
+	// refuse the whole batch if any operation is bad on its face
+	valid := true
+	for i, op := range ops {
+		if err := checkOperation(op); err != nil {
+			results[i] = model.BatchResult{ID: op.ID, Err: err}
+			valid = false
+		}
+	}
+	if !valid {
+		abort(ops, results, -1)
+		return results, nil
+	}
+
+	failed := -1
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		for i, op := range ops {
+			results[i] = c.applyOperation(tx, op)
+			if results[i].Err != nil {
+				failed = i
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	switch {
+	case failed >= 0:
+		abort(ops, results, failed)
+	case err != nil:
+		// the commit itself failed, so did every operation
+		for i := range results {
+			results[i] = model.BatchResult{ID: ops[i].ID, Err: err}
+		}
+	}
+	return results, nil
+}
+
+// applyAlone runs op in a transaction of its own.
+func (c *carService) applyAlone(op model.BatchOperation) model.BatchResult {
+	var result model.BatchResult
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		result = c.applyOperation(tx, op)
+		return result.Err
+	})
+	if err != nil && result.Err == nil {
+		result = model.BatchResult{ID: op.ID, Err: err}
+	}
+	return result
+}
+
+// applyOperation performs op on store.
+func (c *carService) applyOperation(store repository.Store, op model.BatchOperation) model.BatchResult {
+	car := op.Car.Clone()
+	if car != nil {
+		car.UpdatedBy = op.Actor
+	}
+
+	switch op.Op {
+	case model.BatchCreate:
+		if err := c.createCar(store, "", car); err != nil {
+			return model.BatchResult{Err: err}
+		}
+		return model.BatchResult{ID: car.ID, Car: car}
+	case model.BatchUpdate:
+		if car != nil {
+			car.Version = op.Version
+		}
+		if err := c.updateCar(store, op.ID, car); err != nil {
+			return model.BatchResult{ID: op.ID, Err: err}
+		}
+		return model.BatchResult{ID: op.ID, Car: car}
+	case model.BatchDelete:
+		if err := c.deleteCar(store, op.ID, op.Version, op.Actor); err != nil {
+			return model.BatchResult{ID: op.ID, Err: err}
+		}
+		return model.BatchResult{ID: op.ID}
+	}
+	return model.BatchResult{ID: op.ID, Err: fmt.Errorf("%w: unknown op %q", utils.ErrBadBody, op.Op)}
+}
+
+// checkOperation reports the problems with op that need no storage to find.
+func checkOperation(op model.BatchOperation) error {
+	switch op.Op {
+	case model.BatchCreate, model.BatchUpdate:
+		if op.Car == nil {
+			return fmt.Errorf("%w: %s needs a car", utils.ErrEmptyInput, op.Op)
+		}
+		if op.Op == model.BatchUpdate && op.ID == "" {
+			return fmt.Errorf("%w: update needs an id", utils.ErrBadBody)
+		}
+		return validator.Validate(op.Car)
+	case model.BatchDelete:
+		if op.ID == "" {
+			return fmt.Errorf("%w: delete needs an id", utils.ErrBadBody)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown op %q", utils.ErrBadBody, op.Op)
+}
+
+// abort marks every operation but the failed one, and those that already have
+// an error, as aborted.
+func abort(ops []model.BatchOperation, results []model.BatchResult, failed int) {
+	for i := range results {
+		if i == failed || results[i].Err != nil {
+			continue
+		}
+		results[i] = model.BatchResult{ID: ops[i].ID, Err: utils.ErrAborted}
+	}
+}
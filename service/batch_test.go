@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/service"
+	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyBatch(t *testing.T) {
+	newService := func() (*repository.Repo, service.CarService, *model.Car) {
+		mockRepo := repository.NewRepo()
+		carService := service.NewCarService(mockRepo, service.WithIDGenerator(utils.NewSequenceGenerator("car-")))
+
+		existing := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 1500000}
+		assert.NoError(t, carService.CreateCar(existing))
+		return mockRepo, carService, existing
+	}
+
+	t.Run("Applies every operation on its own", func(t *testing.T) {
+		_, carService, existing := newService()
+
+		results, err := carService.ApplyBatch([]model.BatchOperation{
+			{Op: model.BatchCreate, Car: &model.Car{Make: "Honda", Model: "Civic", Year: 2020}},
+			{Op: model.BatchCreate, Car: &model.Car{Make: "Honda", Year: 2020}},
+			{Op: model.BatchUpdate, ID: existing.ID, Car: &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 1400000}},
+			{Op: model.BatchDelete, ID: "missing"},
+		}, false)
+		assert.NoError(t, err)
+		assert.Len(t, results, 4)
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "car-000002", results[0].ID)
+
+		var invalid *validator.ValidationError
+		assert.ErrorAs(t, results[1].Err, &invalid)
+
+		assert.NoError(t, results[2].Err)
+		assert.Equal(t, 1400000, results[2].Car.Price)
+
+		assert.ErrorIs(t, results[3].Err, utils.ErrNotFound)
+
+		cars, err := carService.GetCars()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 2)
+	})
+
+	t.Run("Atomic batch refuses invalid operations up front", func(t *testing.T) {
+		_, carService, _ := newService()
+
+		results, err := carService.ApplyBatch([]model.BatchOperation{
+			{Op: model.BatchCreate, Car: &model.Car{Make: "Honda", Model: "Civic", Year: 2020}},
+			{Op: "upsert", ID: "car-000001"},
+		}, true)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, utils.ErrAborted)
+		assert.ErrorIs(t, results[1].Err, utils.ErrBadBody)
+
+		cars, err := carService.GetCars()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+	})
+
+	t.Run("Atomic batch writes nothing when one operation fails", func(t *testing.T) {
+		mockRepo, carService, existing := newService()
+
+		results, err := carService.ApplyBatch([]model.BatchOperation{
+			{Op: model.BatchCreate, Car: &model.Car{Make: "Honda", Model: "Civic", Year: 2020}},
+			{Op: model.BatchUpdate, ID: existing.ID, Car: &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 1}},
+			{Op: model.BatchDelete, ID: existing.ID, Version: 1},
+		}, true)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, utils.ErrAborted)
+		assert.ErrorIs(t, results[1].Err, utils.ErrAborted)
+		assert.ErrorIs(t, results[2].Err, utils.ErrPreconditionFailed)
+
+		cars, err := mockRepo.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+		assert.Equal(t, 1500000, cars[0].Price)
+		assert.Equal(t, int64(1), cars[0].Version)
+	})
+
+	t.Run("Rejects empty and oversized batches", func(t *testing.T) {
+		_, carService, _ := newService()
+
+		_, err := carService.ApplyBatch(nil, false)
+		assert.ErrorIs(t, err, utils.ErrEmptyInput)
+
+		_, err = carService.ApplyBatch(make([]model.BatchOperation, model.MaxBatchSize+1), false)
+		assert.ErrorIs(t, err, utils.ErrBadBody)
+	})
+}
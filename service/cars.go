@@ -1,47 +1,449 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/patch"
 	"github.com/DalvinCodes/cars/repository"
+	"github.com/DalvinCodes/cars/search"
 	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
 )
 
 type CarService interface {
 	CreateCar(car *model.Car) error
 	UpdateCar(id string, car *model.Car) error
+	PatchCar(id string, version int64, actor string, p patch.Patch) (*model.Car, error)
 	DeleteCar(id string) error
+	DeleteCarVersion(id string, version int64, actor string) error
+	RestoreCar(id string, version int64, actor string) (*model.Car, error)
+	PurgeDeletedCars(before time.Time) (int, error)
 	GetCar(id string) (*model.Car, error)
+	GetCarIncludingDeleted(id string) (*model.Car, error)
 	GetCars() ([]*model.Car, error)
+	FindCars(query model.Query) ([]*model.Car, error)
+	StreamCars(query model.Query, fn func(car *model.Car) error) error
+	ListCars(query model.Query, page model.PageRequest) (*model.Page, error)
+	CarRevisions(id string) ([]*model.Revision, error)
+	CarRevision(id string, number int) (*model.Revision, error)
+	DiffCarRevisions(id string, from, to int) ([]model.Change, error)
+	CarAsOf(id string, at time.Time) (*model.Car, error)
+	ApplyBatch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error)
+	ImportCar(car *model.Car) (bool, error)
+	SearchCars(text string, query model.Query, limit int) ([]model.SearchHit, error)
+	CarFacets(query model.Query, specs []model.FacetSpec) (*model.Facets, error)
 }
 
 type carService struct {
 	repo repository.Storage
+	ids  utils.IDGenerator
+	now  func() time.Time
+}
+
+// Option customises a carService.
+type Option func(*carService)
+
+// WithIDGenerator sets the generator used for the IDs of new cars.
+func WithIDGenerator(ids utils.IDGenerator) Option {
+	return func(c *carService) {
+		c.ids = ids
+	}
+}
+
+// WithClock sets the clock that timestamps soft deletes.
+func WithClock(now func() time.Time) Option {
+	return func(c *carService) {
+		c.now = now
+	}
 }
 
-func NewCarService(repo repository.Storage) *carService {
-	return &carService{
+func NewCarService(repo repository.Storage, opts ...Option) *carService {
+	c := &carService{
 		repo: repo,
+		ids:  utils.NewULIDGenerator(),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
+// GetCar returns the car stored under id unless it is soft deleted.
 func (c *carService) GetCar(id string) (*model.Car, error) {
+	return getCar(c.repo, id)
+}
+
+func (c *carService) GetCarIncludingDeleted(id string) (*model.Car, error) {
 	return c.repo.Get(id)
 }
 
 func (c *carService) GetCars() ([]*model.Car, error) {
-	return c.repo.GetAll()
+	return c.repo.Find(model.Query{})
+}
+
+func (c *carService) FindCars(query model.Query) ([]*model.Car, error) {
+	return c.repo.Find(query)
+}
+
+// StreamCars hands fn the cars matching query one at a time, in query order,
+// and stops at the first error fn returns.
+func (c *carService) StreamCars(query model.Query, fn func(car *model.Car) error) error {
+	return c.repo.Iterate(query, fn)
+}
+
+func (c *carService) ListCars(query model.Query, page model.PageRequest) (*model.Page, error) {
+	cars, err := c.repo.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	return model.Paginate(cars, query, page)
 }
 
 func (c *carService) CreateCar(car *model.Car) error {
-	car.ID = utils.GenerateID()
-	return c.repo.Save(car.ID, car)
+	return c.createCar(c.repo, "", car)
 }
 
+// UpdateCar replaces the car stored under id. A non-zero car.Version must match
+// the stored version or utils.ErrPreconditionFailed is returned. Soft deleted
+// cars have to be restored before they can be updated.
 func (c *carService) UpdateCar(id string, car *model.Car) error {
-	car.ID = id
-	return c.repo.Update(id, car)
+	return repository.WithTx(c.repo, func(tx repository.Tx) error {
+		return c.updateCar(tx, id, car)
+	})
+}
+
+// PatchCar applies p to the stored car and saves the result, made by actor, if
+// it is still valid. A non-zero version must match the stored version.
+func (c *carService) PatchCar(id string, version int64, actor string, p patch.Patch) (*model.Car, error) {
+	var patched *model.Car
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		var err error
+		patched, err = c.patchCar(tx, id, version, actor, p)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patched, nil
 }
 
 func (c *carService) DeleteCar(id string) error {
-	return c.repo.Delete(id)
+	return c.DeleteCarVersion(id, 0, "")
+}
+
+// DeleteCarVersion soft deletes the car stored under id on behalf of actor if
+// it is at version, or whatever its version when version is 0.
+func (c *carService) DeleteCarVersion(id string, version int64, actor string) error {
+	return repository.WithTx(c.repo, func(tx repository.Tx) error {
+		return c.deleteCar(tx, id, version, actor)
+	})
+}
+
+// RestoreCar undoes the soft delete of the car stored under id on behalf of
+// actor. A non-zero version must match the stored version.
+func (c *carService) RestoreCar(id string, version int64, actor string) (*model.Car, error) {
+	var car *model.Car
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		var err error
+		car, err = tx.Get(id)
+		if err != nil {
+			return err
+		}
+		if !car.Deleted() {
+			return fmt.Errorf("%w: car %s is not deleted", utils.ErrConflict, id)
+		}
+		if err := checkVersion(car, version); err != nil {
+			return err
+		}
+
+		car.DeletedAt = nil
+		car.UpdatedBy = actor
+		return tx.Update(id, car)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// ImportCar updates the live car with the ID of car if there is one and creates
+// a car otherwise, under the ID of car when it has one, and reports whether it
+// created one. A soft deleted car is left alone with utils.ErrConflict; it has
+// to be restored before it can be imported over. An ID the car could not be
+// reached under is utils.ErrUnprocessable.
+func (c *carService) ImportCar(car *model.Car) (bool, error) {
+	created := false
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		if car == nil || car.ID == "" {
+			created = true
+			return c.createCar(tx, "", car)
+		}
+		if err := checkImportedID(car.ID); err != nil {
+			return err
+		}
+
+		stored, err := tx.Get(car.ID)
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			created = true
+			return c.createCar(tx, car.ID, car)
+		case err != nil:
+			return err
+		case stored.Deleted():
+			return fmt.Errorf("%w: car %s is deleted", utils.ErrConflict, car.ID)
+		}
+		return c.updateCar(tx, car.ID, car)
+	})
+	return created, err
+}
+
+// PurgeDeletedCars removes the cars soft deleted before the given time for good
+// and returns how many it removed.
+func (c *carService) PurgeDeletedCars(before time.Time) (int, error) {
+	purged := 0
+	err := repository.WithTx(c.repo, func(tx repository.Tx) error {
+		cars, err := tx.Find(model.Query{IncludeDeleted: true})
+		if err != nil {
+			return err
+		}
+
+		for _, car := range cars {
+			if !car.Deleted() || !car.DeletedAt.Before(before) {
+				continue
+			}
+			if err := tx.Delete(car.ID); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// reservedIDs are the words routed under /cars/ in place of a car ID.
+var reservedIDs = map[string]bool{
+	"all":    true,
+	"batch":  true,
+	"create": true,
+	"delete": true,
+	"export": true,
+	"facets": true,
+	"import": true,
+	"search": true,
+	"update": true,
+}
+
+// checkImportedID rejects an ID the API could not reach a car under: a word
+// routed in its place, or one that spans more than a path segment.
+func checkImportedID(id string) error {
+	if reservedIDs[id] || strings.Contains(id, "/") {
+		return fmt.Errorf("%w: id %q cannot be used for a car", utils.ErrUnprocessable, id)
+	}
+	return nil
+}
+
+func getCar(store repository.Store, id string) (*model.Car, error) {
+	car, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if car.Deleted() {
+		return nil, fmt.Errorf("%w: car %s is deleted", utils.ErrNotFound, id)
+	}
+	return car, nil
+}
+
+// createCar validates car and saves it as a new car under id, or under a new ID
+// when id is empty.
+func (c *carService) createCar(store repository.Store, id string, car *model.Car) error {
+	if car == nil {
+		return utils.ErrEmptyInput
+	}
+	if err := validator.Validate(car); err != nil {
+		return err
+	}
+
+	car.ID = id
+	if car.ID == "" {
+		car.ID = c.ids.NewID()
+	}
+	car.DeletedAt = nil
+	return store.Save(car.ID, car)
+}
+
+func (c *carService) updateCar(store repository.Store, id string, car *model.Car) error {
+	if car == nil {
+		return utils.ErrEmptyInput
+	}
+	if err := validator.Validate(car); err != nil {
+		return err
+	}
+	if _, err := getCar(store, id); err != nil {
+		return err
+	}
+
+	car.ID = id
+	car.DeletedAt = nil
+	return store.Update(id, car)
+}
+
+func (c *carService) patchCar(store repository.Store, id string, version int64, actor string, p patch.Patch) (*model.Car, error) {
+	car, err := getCar(store, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(car, version); err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(car)
+	if err != nil {
+		return nil, err
+	}
+	doc, err = p.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched *model.Car
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return nil, fmt.Errorf("%w: patched car: %v", utils.ErrUnprocessable, err)
+	}
+	patched.Version = car.Version
+	patched.UpdatedBy = actor
+
+	if err := c.updateCar(store, id, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+func (c *carService) deleteCar(store repository.Store, id string, version int64, actor string) error {
+	car, err := getCar(store, id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(car, version); err != nil {
+		return err
+	}
+
+	deletedAt := c.now()
+	car.DeletedAt = &deletedAt
+	car.UpdatedBy = actor
+	return store.Update(id, car)
+}
+
+func checkVersion(car *model.Car, version int64) error {
+	if version != 0 && version != car.Version {
+		return fmt.Errorf("%w: car is at version %d, not %d", utils.ErrPreconditionFailed, car.Version, version)
+	}
+	return nil
+}
+
+func (c *carService) CarRevisions(id string) ([]*model.Revision, error) {
+	history, err := c.history()
+	if err != nil {
+		return nil, err
+	}
+	return history.Revisions(id)
+}
+
+func (c *carService) CarRevision(id string, number int) (*model.Revision, error) {
+	history, err := c.history()
+	if err != nil {
+		return nil, err
+	}
+	return history.Revision(id, number)
+}
+
+// DiffCarRevisions lists the fields that changed between two revisions of a car.
+func (c *carService) DiffCarRevisions(id string, from, to int) ([]model.Change, error) {
+	history, err := c.history()
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := history.Revision(id, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := history.Revision(id, to)
+	if err != nil {
+		return nil, err
+	}
+	return model.Diff(before.Car, after.Car), nil
+}
+
+// CarAsOf returns the car as it was at the given time.
+func (c *carService) CarAsOf(id string, at time.Time) (*model.Car, error) {
+	history, err := c.history()
+	if err != nil {
+		return nil, err
+	}
+	return history.AsOf(id, at)
+}
+
+// history returns the History the storage records, if it records one.
+func (c *carService) history() (repository.History, error) {
+	history, ok := repository.As[repository.History](c.repo)
+	if !ok {
+		return nil, fmt.Errorf("%w: car history is not recorded", utils.ErrNotSupported)
+	}
+	return history, nil
+}
+
+// SearchCars finds the live cars matching text and query by relevance, up to
+// limit of them, model.DefaultSearchLimit when it is 0.
+func (c *carService) SearchCars(text string, query model.Query, limit int) ([]model.SearchHit, error) {
+	searcher, ok := repository.As[repository.Searcher](c.repo)
+	if !ok {
+		return nil, fmt.Errorf("%w: cars are not indexed for search", utils.ErrNotSupported)
+	}
+	if len(search.Tokenize(text)) == 0 {
+		return nil, fmt.Errorf("%w: the search text has no words", utils.ErrBadQuery)
+	}
+	if limit <= 0 {
+		limit = model.DefaultSearchLimit
+	}
+	if limit > model.MaxSearchLimit {
+		limit = model.MaxSearchLimit
+	}
+	return searcher.Search(text, query, limit)
+}
+
+// CarFacets counts the cars matching query by the facets specs ask for, in the
+// storage when it can aggregate and otherwise car by car.
+func (c *carService) CarFacets(query model.Query, specs []model.FacetSpec) (*model.Facets, error) {
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrBadQuery, err)
+		}
+	}
+
+	if aggregator, ok := repository.As[repository.Aggregator](c.repo); ok {
+		return aggregator.Facets(query, specs)
+	}
+
+	counter := model.NewFacetCounter(specs)
+	err := c.repo.Iterate(query, func(car *model.Car) error {
+		counter.Add(car)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counter.Facets(), nil
 }
@@ -2,11 +2,14 @@ package service_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/patch"
 	"github.com/DalvinCodes/cars/repository"
 	"github.com/DalvinCodes/cars/service"
 	"github.com/DalvinCodes/cars/utils"
+	"github.com/DalvinCodes/cars/validator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -127,9 +130,7 @@ func TestDelete(t *testing.T) {
 		}
 
 		delCar, err := carService.GetCar(car.ID)
-		if err != nil {
-			t.Error("error getting car")
-		}
+		assert.ErrorIs(t, err, utils.ErrNotFound)
 
 		assert.Nil(t, delCar)
 	})
@@ -209,3 +210,319 @@ func TestGetAll(t *testing.T) {
 
 	assert.Equal(t, len(cars), 2)
 }
+
+func TestFind(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo)
+
+	camry := &model.Car{Make: "Toyota", Model: "Camry", Color: "White", Year: 2019, Price: 1500000}
+	civic := &model.Car{Make: "Honda", Model: "Civic", Color: "Red", Year: 2020, Price: 2000000}
+	_ = mockRepo.Save("camry", camry)
+	_ = mockRepo.Save("civic", civic)
+
+	cars, err := carService.FindCars(model.Query{Color: "red"})
+	if err != nil {
+		t.Error("error finding cars")
+	}
+
+	assert.Equal(t, []*model.Car{civic}, cars)
+}
+
+func TestList(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo)
+
+	for _, id := range []string{"a", "b", "c"} {
+		_ = mockRepo.Save(id, &model.Car{ID: id, Make: "Toyota"})
+	}
+
+	page, err := carService.ListCars(model.Query{}, model.PageRequest{Limit: 2})
+	if err != nil {
+		t.Error("error listing cars")
+	}
+
+	assert.Len(t, page.Cars, 2)
+	assert.Equal(t, 3, page.Total)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestCreateInvalid(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo)
+
+	err := carService.CreateCar(&model.Car{Make: "Toyota", Price: -1})
+
+	var invalid *validator.ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Errors, 3)
+
+	cars, _ := carService.GetCars()
+	assert.Empty(t, cars)
+
+	assert.ErrorIs(t, carService.CreateCar(nil), utils.ErrEmptyInput)
+}
+
+func TestCreateWithIDGenerator(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo, service.WithIDGenerator(utils.NewSequenceGenerator("car-")))
+
+	first := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019}
+	second := &model.Car{Make: "Honda", Model: "Civic", Year: 2020}
+	assert.NoError(t, carService.CreateCar(first))
+	assert.NoError(t, carService.CreateCar(second))
+
+	assert.Equal(t, "car-000001", first.ID)
+	assert.Equal(t, "car-000002", second.ID)
+
+	cars, err := carService.GetCars()
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Car{first, second}, cars)
+}
+
+func TestPatch(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo)
+
+	car := &model.Car{Make: "Toyota", Model: "Camry", Color: "White", Year: 2019, Mileage: 10000, Price: 1500000}
+	assert.NoError(t, carService.CreateCar(car))
+
+	t.Run("Merge patch changes only the given fields", func(t *testing.T) {
+		patched, err := carService.PatchCar(car.ID, 0, "", patch.MergePatch(`{"price":1400000}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 1400000, patched.Price)
+
+		object, err := carService.GetCar(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1400000, object.Price)
+		assert.Equal(t, "White", object.Color)
+	})
+
+	t.Run("Patched car is validated", func(t *testing.T) {
+		p, err := patch.ParseJSONPatch([]byte(`[{"op":"replace","path":"/mileage","value":-5}]`))
+		assert.NoError(t, err)
+
+		_, err = carService.PatchCar(car.ID, 0, "", p)
+		var invalid *validator.ValidationError
+		assert.ErrorAs(t, err, &invalid)
+
+		object, err := carService.GetCar(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 10000, object.Mileage)
+	})
+
+	t.Run("ID cannot be changed", func(t *testing.T) {
+		patched, err := carService.PatchCar(car.ID, 0, "", patch.MergePatch(`{"id":"other"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, car.ID, patched.ID)
+	})
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		_, err := carService.PatchCar(car.ID, 0, "", patch.MergePatch(`{"horsepower":300}`))
+		assert.ErrorIs(t, err, utils.ErrUnprocessable)
+	})
+
+	t.Run("Missing car", func(t *testing.T) {
+		_, err := carService.PatchCar("missing", 0, "", patch.MergePatch(`{"price":1}`))
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+	})
+}
+
+func TestVersions(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo)
+
+	car := &model.Car{Make: "Toyota", Model: "Camry", Color: "White", Year: 2019, Mileage: 10000, Price: 1500000}
+	assert.NoError(t, carService.CreateCar(car))
+	assert.Equal(t, int64(1), car.Version)
+
+	t.Run("Stale update is rejected", func(t *testing.T) {
+		update := &model.Car{Make: "Toyota", Model: "Camry", Color: "Red", Year: 2019, Version: 1}
+		assert.NoError(t, carService.UpdateCar(car.ID, update))
+		assert.Equal(t, int64(2), update.Version)
+
+		stale := &model.Car{Make: "Toyota", Model: "Camry", Color: "Blue", Year: 2019, Version: 1}
+		assert.ErrorIs(t, carService.UpdateCar(car.ID, stale), utils.ErrPreconditionFailed)
+	})
+
+	t.Run("Stale patch is rejected", func(t *testing.T) {
+		_, err := carService.PatchCar(car.ID, 1, "", patch.MergePatch(`{"color":"Blue"}`))
+		assert.ErrorIs(t, err, utils.ErrPreconditionFailed)
+
+		patched, err := carService.PatchCar(car.ID, 2, "", patch.MergePatch(`{"color":"Blue","version":99}`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), patched.Version)
+	})
+
+	t.Run("Stale delete is rejected", func(t *testing.T) {
+		assert.ErrorIs(t, carService.DeleteCarVersion(car.ID, 2, ""), utils.ErrPreconditionFailed)
+		assert.NoError(t, carService.DeleteCarVersion(car.ID, 3, ""))
+	})
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo := repository.NewHistoryStore(repository.NewRepo(), func() time.Time { return now })
+	carService := service.NewCarService(mockRepo)
+
+	car := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Mileage: 10000, Price: 1500000, UpdatedBy: "alice"}
+	assert.NoError(t, carService.CreateCar(car))
+
+	now = now.Add(time.Hour)
+	_, err := carService.PatchCar(car.ID, 0, "bob", patch.MergePatch(`{"mileage":12000}`))
+	assert.NoError(t, err)
+
+	t.Run("Lists revisions with their actors", func(t *testing.T) {
+		revisions, err := carService.CarRevisions(car.ID)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 2)
+		assert.Equal(t, "alice", revisions[0].Actor)
+		assert.Equal(t, "bob", revisions[1].Actor)
+	})
+
+	t.Run("Diffs two revisions", func(t *testing.T) {
+		changes, err := carService.DiffCarRevisions(car.ID, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"mileage", "updated_by", "version"}, changeFields(changes))
+
+		_, err = carService.DiffCarRevisions(car.ID, 1, 3)
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+	})
+
+	t.Run("Reads the car as of a time", func(t *testing.T) {
+		object, err := carService.CarAsOf(car.ID, now.Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 10000, object.Mileage)
+	})
+
+	t.Run("Storage without history", func(t *testing.T) {
+		_, err := service.NewCarService(repository.NewRepo()).CarRevisions(car.ID)
+		assert.ErrorIs(t, err, utils.ErrNotSupported)
+	})
+}
+
+func changeFields(changes []model.Change) []string {
+	fields := []string{}
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	return fields
+}
+
+func TestSoftDelete(t *testing.T) {
+	now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo, service.WithClock(func() time.Time { return now }))
+
+	car := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: 1500000}
+	assert.NoError(t, carService.CreateCar(car))
+	other := &model.Car{Make: "Honda", Model: "Civic", Year: 2020, Price: 2000000}
+	assert.NoError(t, carService.CreateCar(other))
+
+	t.Run("Deleted car is hidden but kept", func(t *testing.T) {
+		assert.NoError(t, carService.DeleteCarVersion(car.ID, 0, "alice"))
+
+		cars, err := carService.GetCars()
+		assert.NoError(t, err)
+		assert.Len(t, cars, 1)
+
+		cars, err = carService.FindCars(model.Query{IncludeDeleted: true})
+		assert.NoError(t, err)
+		assert.Len(t, cars, 2)
+
+		deleted, err := carService.GetCarIncludingDeleted(car.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, now, *deleted.DeletedAt)
+		assert.Equal(t, "alice", deleted.UpdatedBy)
+
+		update := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019}
+		assert.ErrorIs(t, carService.UpdateCar(car.ID, update), utils.ErrNotFound)
+		assert.ErrorIs(t, carService.DeleteCar(car.ID), utils.ErrNotFound)
+	})
+
+	t.Run("Restore brings the car back", func(t *testing.T) {
+		restored, err := carService.RestoreCar(car.ID, 0, "bob")
+		assert.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+
+		_, err = carService.GetCar(car.ID)
+		assert.NoError(t, err)
+
+		_, err = carService.RestoreCar(car.ID, 0, "bob")
+		assert.ErrorIs(t, err, utils.ErrConflict)
+	})
+
+	t.Run("Purge removes cars deleted before the cutoff", func(t *testing.T) {
+		assert.NoError(t, carService.DeleteCar(car.ID))
+		now = now.Add(48 * time.Hour)
+		assert.NoError(t, carService.DeleteCar(other.ID))
+
+		purged, err := carService.PurgeDeletedCars(now.Add(-24 * time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = mockRepo.Get(car.ID)
+		assert.ErrorIs(t, err, utils.ErrNotFound)
+		_, err = mockRepo.Get(other.ID)
+		assert.NoError(t, err)
+	})
+}
+
+func TestImport(t *testing.T) {
+	mockRepo := repository.NewRepo()
+	carService := service.NewCarService(mockRepo, service.WithIDGenerator(utils.NewSequenceGenerator("car-")))
+
+	t.Run("Rows without an ID get a new one", func(t *testing.T) {
+		car := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019}
+		created, err := carService.ImportCar(car)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, "car-000001", car.ID)
+	})
+
+	t.Run("Rows with an unknown ID keep it", func(t *testing.T) {
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2020}
+		created, err := carService.ImportCar(car)
+		assert.NoError(t, err)
+		assert.True(t, created)
+
+		stored, err := carService.GetCar("civic")
+		assert.NoError(t, err)
+		assert.Equal(t, "Civic", stored.Model)
+	})
+
+	t.Run("Rows with a known ID update the car", func(t *testing.T) {
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2021}
+		created, err := carService.ImportCar(car)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, int64(2), car.Version)
+	})
+
+	t.Run("Rows with the ID of a deleted car conflict", func(t *testing.T) {
+		assert.NoError(t, carService.DeleteCarVersion("civic", 0, "alice"))
+
+		car := &model.Car{ID: "civic", Make: "Honda", Model: "Civic", Year: 2022}
+		_, err := carService.ImportCar(car)
+		assert.ErrorIs(t, err, utils.ErrConflict)
+
+		stored, err := carService.GetCarIncludingDeleted("civic")
+		assert.NoError(t, err)
+		assert.True(t, stored.Deleted())
+		assert.Equal(t, 2021, stored.Year)
+
+		cars, err := carService.FindCars(model.Query{IncludeDeleted: true})
+		assert.NoError(t, err)
+		assert.Len(t, cars, 2)
+	})
+
+	t.Run("Rows with an ID the API cannot reach are rejected", func(t *testing.T) {
+		for _, id := range []string{"export", "search", "batch", "honda/civic"} {
+			car := &model.Car{ID: id, Make: "Honda", Model: "Civic", Year: 2022}
+			_, err := carService.ImportCar(car)
+			assert.ErrorIs(t, err, utils.ErrUnprocessable, id)
+
+			_, err = carService.GetCarIncludingDeleted(id)
+			assert.ErrorIs(t, err, utils.ErrNotFound, id)
+		}
+	})
+}
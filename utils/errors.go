@@ -12,4 +12,17 @@ var (
 var (
 	ErrNotFound   = errors.New("object not found")
 	ErrEmptyInput = errors.New("empty input")
+	ErrBadQuery   = errors.New("invalid query")
+	ErrBadBody    = errors.New("malformed request body")
+	ErrConflict   = errors.New("conflict")
+
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	ErrUnprocessable        = errors.New("unprocessable entity")
+	ErrMethodNotAllowed     = errors.New("method not allowed")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("not acceptable")
+	ErrNotSupported         = errors.New("not supported by the storage backend")
+	ErrAborted              = errors.New("aborted because another operation in the batch failed")
+	ErrTxDone               = errors.New("transaction has already been committed or rolled back")
 )
@@ -1,13 +1,118 @@
 package utils
 
 import (
-	"math/rand"
-	"strconv"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// IDGenerator hands out unique IDs. IDs from one generator sort in the order
+// they were generated.
+type IDGenerator interface {
+	NewID() string
+}
 
+var defaultIDGenerator IDGenerator = NewULIDGenerator()
 
+// GenerateID returns a new ULID from the default generator.
 func GenerateID() string {
-	ranNum := rand.NewSource(int64(rand.Intn(100)))
-	return strconv.FormatInt(ranNum.Int63(), 10)
+	return defaultIDGenerator.NewID()
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator produces ULIDs: a 48-bit millisecond timestamp followed by 80
+// random bits, encoded as 26 characters of Crockford base32. IDs generated in
+// the same millisecond increment the random part, so they stay ordered.
+type ULIDGenerator struct {
+	now     func() time.Time
+	entropy io.Reader
+
+	mu     sync.Mutex
+	lastMs uint64
+	last   [10]byte
+}
+
+func NewULIDGenerator() *ULIDGenerator {
+	return NewULIDGeneratorWith(time.Now, rand.Reader)
+}
+
+// NewULIDGeneratorWith uses the given clock and source of randomness, which
+// makes the IDs reproducible in tests.
+func NewULIDGeneratorWith(now func() time.Time, entropy io.Reader) *ULIDGenerator {
+	return &ULIDGenerator{
+		now:     now,
+		entropy: entropy,
+	}
+}
+
+func (g *ULIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs {
+		// same millisecond, or the clock went backwards: keep counting from the last ID
+		ms = g.lastMs
+		if increment(g.last[:]) {
+			// the random part overflowed, borrow the next millisecond
+			ms++
+		}
+	} else if _, err := io.ReadFull(g.entropy, g.last[:]); err != nil {
+		panic(fmt.Sprintf("utils: reading entropy for ULID: %v", err))
+	}
+	g.lastMs = ms
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], g.last[:])
+	return encodeULID(id)
+}
+
+// increment adds one to a big-endian number and reports whether it overflowed.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID writes 128 bits as 26 base32 characters, the first holding only
+// the top 3 bits.
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// SequenceGenerator produces predictable IDs such as "car-000001" for tests.
+type SequenceGenerator struct {
+	prefix string
+	next   atomic.Int64
+}
+
+func NewSequenceGenerator(prefix string) *SequenceGenerator {
+	return &SequenceGenerator{
+		prefix: prefix,
+	}
+}
+
+func (g *SequenceGenerator) NewID() string {
+	return fmt.Sprintf("%s%06d", g.prefix, g.next.Add(1))
 }
@@ -1,10 +1,14 @@
 package utils_test
 
 import (
+	"bytes"
 	"log"
+	"sort"
 	"testing"
+	"time"
 
 	utils "github.com/DalvinCodes/cars/utils"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateID(t *testing.T) {
@@ -15,9 +19,52 @@ func TestGenerateID(t *testing.T) {
 
 	log.Printf("ID: %s", id)
 
-	if len(id) > 19 {
-		t.Error("ID not 19 characters long or less")
+	if len(id) != 26 {
+		t.Error("ID not 26 characters long")
 	}
 
-	
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := utils.GenerateID()
+		if seen[id] {
+			t.Fatalf("duplicate ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestULIDGenerator(t *testing.T) {
+	t.Run("Deterministic with a fixed clock and entropy", func(t *testing.T) {
+		now := func() time.Time { return time.UnixMilli(1700000000000) }
+		entropy := bytes.NewReader(make([]byte, 10))
+		gen := utils.NewULIDGeneratorWith(now, entropy)
+
+		assert.Equal(t, "01HF7YAT000000000000000000", gen.NewID())
+		// the same millisecond increments the random part instead of reading more entropy
+		assert.Equal(t, "01HF7YAT000000000000000001", gen.NewID())
+	})
+
+	t.Run("IDs sort in generation order", func(t *testing.T) {
+		// the clock only moves on every third call
+		calls := int64(0)
+		now := func() time.Time {
+			calls++
+			return time.UnixMilli(1700000000000 + calls/3)
+		}
+		gen := utils.NewULIDGeneratorWith(now, bytes.NewReader(bytes.Repeat([]byte{0xff}, 1000)))
+
+		var ids []string
+		for i := 0; i < 50; i++ {
+			ids = append(ids, gen.NewID())
+		}
+
+		assert.True(t, sort.StringsAreSorted(ids))
+	})
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	gen := utils.NewSequenceGenerator("car-")
+
+	assert.Equal(t, "car-000001", gen.NewID())
+	assert.Equal(t, "car-000002", gen.NewID())
 }
@@ -0,0 +1,146 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagName = "validate"
+
+// FieldError describes one field that failed a rule.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationError lists every field of a value that failed validation.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Rule checks a single field against the parameter given in its tag, e.g. the
+// "64" of max=64, and returns a message when the value is invalid.
+type Rule func(value reflect.Value, param string) (string, bool)
+
+var rules = map[string]Rule{
+	"required": required,
+	"min":      minimum,
+	"max":      maximum,
+	"maxyear":  maxYear,
+}
+
+// Validate checks every field of the struct v points to against the rules in
+// its validate tags. It returns a *ValidationError when any field is invalid.
+func Validate(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validator: cannot validate %T", v)
+	}
+
+	var errs []FieldError
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(tagName)
+		if tag == "" {
+			continue
+		}
+
+		for _, clause := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(clause, "=")
+			rule, ok := rules[name]
+			if !ok {
+				return fmt.Errorf("validator: unknown rule %q on field %s", name, field.Name)
+			}
+
+			if message, ok := rule(value.Field(i), param); !ok {
+				errs = append(errs, FieldError{Field: fieldName(field), Rule: name, Message: message})
+				// report only the first broken rule of each field
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// fieldName reports a field by its JSON name so errors match the request body.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func required(value reflect.Value, _ string) (string, bool) {
+	if value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "") {
+		return "is required", false
+	}
+	return "", true
+}
+
+// minimum is the lowest allowed number, or the shortest allowed string.
+func minimum(value reflect.Value, param string) (string, bool) {
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		return fmt.Sprintf("has an invalid min rule %q", param), false
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if len([]rune(value.String())) < limit {
+			return fmt.Sprintf("must be at least %d characters", limit), false
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() < int64(limit) {
+			return fmt.Sprintf("must be at least %d", limit), false
+		}
+	}
+	return "", true
+}
+
+// maximum is the highest allowed number, or the longest allowed string.
+func maximum(value reflect.Value, param string) (string, bool) {
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		return fmt.Sprintf("has an invalid max rule %q", param), false
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if len([]rune(value.String())) > limit {
+			return fmt.Sprintf("must be at most %d characters", limit), false
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() > int64(limit) {
+			return fmt.Sprintf("must be at most %d", limit), false
+		}
+	}
+	return "", true
+}
+
+// maxYear rejects years after next year, since manufacturers sell next
+// year's models ahead of time.
+func maxYear(value reflect.Value, _ string) (string, bool) {
+	limit := int64(time.Now().Year() + 1)
+	if value.Int() > limit {
+		return fmt.Sprintf("must be at most %d", limit), false
+	}
+	return "", true
+}
@@ -0,0 +1,46 @@
+package validator_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DalvinCodes/cars/model"
+	"github.com/DalvinCodes/cars/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("Valid car", func(t *testing.T) {
+		car := &model.Car{Make: "Toyota", Model: "Camry", Year: 2019, Mileage: 10000, Price: 1500000}
+		assert.NoError(t, validator.Validate(car))
+	})
+
+	t.Run("Reports every invalid field", func(t *testing.T) {
+		car := &model.Car{Make: " ", Model: "Camry", Year: 1200, Mileage: -5, Price: -1}
+
+		err := validator.Validate(car)
+
+		var invalid *validator.ValidationError
+		assert.True(t, errors.As(err, &invalid))
+		assert.Equal(t, []validator.FieldError{
+			{Field: "make", Rule: "required", Message: "is required"},
+			{Field: "year", Rule: "min", Message: "must be at least 1886"},
+			{Field: "mileage", Rule: "min", Message: "must be at least 0"},
+			{Field: "price", Rule: "min", Message: "must be at least 0"},
+		}, invalid.Errors)
+	})
+
+	t.Run("Rejects years beyond next model year", func(t *testing.T) {
+		car := &model.Car{Make: "Toyota", Model: "Camry", Year: time.Now().Year() + 2}
+
+		var invalid *validator.ValidationError
+		assert.True(t, errors.As(validator.Validate(car), &invalid))
+		assert.Equal(t, "year", invalid.Errors[0].Field)
+		assert.Equal(t, "maxyear", invalid.Errors[0].Rule)
+	})
+
+	t.Run("Rejects non struct values", func(t *testing.T) {
+		assert.Error(t, validator.Validate("camry"))
+	})
+}